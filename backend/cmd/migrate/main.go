@@ -1,41 +1,199 @@
 package main
 
 import (
+	"context"
+	"database/sql"
+	"errors"
+	"flag"
 	"fmt"
 	"log"
 	"os"
+	"strconv"
+	"time"
 
+	_ "github.com/lib/pq"
 	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/config"
+	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/migrate"
+	_ "github.com/timur-harin/sum25-go-flutter-course/backend/migrations"
 )
 
+const usage = "Usage: go run cmd/migrate/main.go [flags] [up [N]|down [N]|goto V|force V|status]\n" +
+	"       go run cmd/migrate/main.go create [-dir DIR] NAME"
+
 func main() {
-	if len(os.Args) < 2 {
-		log.Fatal("Usage: go run cmd/migrate/main.go [up|down]")
+	// create only writes files, so it runs before the configuration is
+	// loaded and validated and an unrelated bad setting can't stop it
+	if len(os.Args) > 1 && os.Args[1] == "create" {
+		if err := createMigration(os.Args[2:]); err != nil {
+			log.Fatalf("❌ %v", err)
+		}
+		return
+	}
+
+	flags := flag.NewFlagSet("migrate", flag.ExitOnError)
+	printConfig := flags.Bool("print-config", false, "print the merged configuration with secrets redacted and exit")
+
+	cfg, err := config.LoadWithFlags(flags, os.Args[1:])
+	if err != nil {
+		log.Fatalf("❌ %v", err)
+	}
+	if *printConfig {
+		cfg.Print(os.Stdout)
+		return
+	}
+	if err := cfg.Validate(); err != nil {
+		log.Fatalf("❌ %v", err)
 	}
 
-	cfg := config.Load()
-	direction := os.Args[1]
+	if flags.NArg() < 1 {
+		log.Fatal(usage)
+	}
+	command := flags.Arg(0)
+	args := flags.Args()[1:]
+
+	migrator, db, err := newMigrator(cfg)
+	if err != nil {
+		log.Fatalf("❌ %v", err)
+	}
+	defer db.Close()
 
-	switch direction {
+	ctx := context.Background()
+
+	switch command {
 	case "up":
-		runMigrationsUp(cfg)
+		err = runMigrationsUp(ctx, migrator, optionalCount(args, 0))
 	case "down":
-		runMigrationsDown(cfg)
+		err = runMigrationsDown(ctx, migrator, optionalCount(args, 1))
+	case "goto":
+		err = runMigrationsGoto(ctx, migrator, requiredVersion(args))
+	case "force":
+		err = runForce(ctx, migrator, requiredVersion(args))
+	case "status":
+		err = printStatus(ctx, migrator)
 	default:
-		log.Fatal("Invalid direction. Use 'up' or 'down'")
+		log.Fatal(usage)
+	}
+
+	if err != nil {
+		log.Fatalf("❌ %v", err)
 	}
 }
 
-func runMigrationsUp(cfg *config.Config) {
-	fmt.Printf("🔄 Running migrations UP against %s\n", cfg.DatabaseURL)
-	// TODO: Implement actual migration logic
-	// This would typically use a migration library like golang-migrate
-	fmt.Println("✅ Migrations completed successfully")
+func newMigrator(cfg *config.Config) (*migrate.Migrator, *sql.DB, error) {
+	migrations, err := migrate.Load(os.DirFS(cfg.MigrationsDir))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	db, err := sql.Open("postgres", cfg.DatabaseURL)
+	if err != nil {
+		return nil, nil, fmt.Errorf("open database: %w", err)
+	}
+
+	m := migrate.New(db, migrations)
+	m.Locker = migrate.PostgresLock{}
+	m.Config = cfg
+	return m, db, nil
+}
+
+func runMigrationsUp(ctx context.Context, m *migrate.Migrator, n int) error {
+	fmt.Println("🔄 Running migrations UP")
+	return report(m.Up(ctx, n), "✅ Migrations completed successfully")
 }
 
-func runMigrationsDown(cfg *config.Config) {
-	fmt.Printf("🔄 Running migrations DOWN against %s\n", cfg.DatabaseURL)
-	// TODO: Implement actual migration logic
-	// This would typically use a migration library like golang-migrate
-	fmt.Println("✅ Migrations rollback completed successfully")
+func runMigrationsDown(ctx context.Context, m *migrate.Migrator, n int) error {
+	fmt.Println("🔄 Running migrations DOWN")
+	return report(m.Down(ctx, n), "✅ Migrations rollback completed successfully")
+}
+
+func runMigrationsGoto(ctx context.Context, m *migrate.Migrator, version uint64) error {
+	fmt.Printf("🔄 Migrating to version %d\n", version)
+	return report(m.Goto(ctx, version), "✅ Migrated successfully")
+}
+
+func runForce(ctx context.Context, m *migrate.Migrator, version uint64) error {
+	fmt.Printf("⚠️  Forcing version %d without running migrations\n", version)
+	return report(m.Force(ctx, version), "✅ Version forced, database marked clean")
+}
+
+func printStatus(ctx context.Context, m *migrate.Migrator) error {
+	statuses, err := m.Status(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, s := range statuses {
+		switch {
+		case s.Dirty:
+			fmt.Printf("💥 %04d_%s (dirty, fix manually and run force)\n", s.Version, s.Name)
+		case s.Missing:
+			fmt.Printf("⚠️  %04d (applied %s, file missing)\n", s.Version, s.AppliedAt.Format("2006-01-02 15:04:05"))
+		case s.Modified:
+			fmt.Printf("❗ %04d_%s (applied %s, file modified since)\n", s.Version, s.Name, s.AppliedAt.Format("2006-01-02 15:04:05"))
+		case s.Applied:
+			fmt.Printf("✅ %04d_%s (applied %s)\n", s.Version, s.Name, s.AppliedAt.Format("2006-01-02 15:04:05"))
+		default:
+			fmt.Printf("⏳ %04d_%s (pending)\n", s.Version, s.Name)
+		}
+	}
+	return nil
+}
+
+// createMigration scaffolds the next up/down pair in -dir, by default
+// MIGRATIONS_DIR
+func createMigration(args []string) error {
+	flags := flag.NewFlagSet("create", flag.ContinueOnError)
+	dir := flags.String("dir", config.Load().MigrationsDir, "directory to create the migration in")
+	if err := flags.Parse(args); errors.Is(err, flag.ErrHelp) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		return errors.New(usage)
+	}
+
+	up, down, err := migrate.Create(*dir, flags.Arg(0), time.Now())
+	if err != nil {
+		return err
+	}
+	fmt.Printf("📝 Created %s\n📝 Created %s\n", up, down)
+	return nil
+}
+
+// report prints the success message, treating "nothing to do" as success
+func report(err error, success string) error {
+	if errors.Is(err, migrate.ErrNoChange) {
+		fmt.Println("✅ Nothing to do, database is up to date")
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Println(success)
+	return nil
+}
+
+// optionalCount parses the step count for up/down. Without an argument up
+// applies everything (0) and down rolls back a single migration (1).
+func optionalCount(args []string, fallback int) int {
+	if len(args) == 0 {
+		return fallback
+	}
+	n, err := strconv.Atoi(args[0])
+	if err != nil || n < 1 {
+		log.Fatalf("Invalid step count %q, expected a positive number", args[0])
+	}
+	return n
+}
+
+func requiredVersion(args []string) uint64 {
+	if len(args) == 0 {
+		log.Fatal(usage)
+	}
+	v, err := strconv.ParseUint(args[0], 10, 64)
+	if err != nil {
+		log.Fatalf("Invalid version %q", args[0])
+	}
+	return v
 }
@@ -2,22 +2,98 @@ package main
 
 import (
 	"context"
+	"errors"
+	"flag"
 	"log"
+	"log/slog"
 	"net/http"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/redis/go-redis/v9"
+	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/auth"
+	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/buildinfo"
 	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/config"
+	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/csp"
+	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/errorreport"
 	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/handlers"
+	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/health"
+	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/idempotency"
+	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/logging"
+	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/metrics"
 	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/middleware"
+	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/migrate"
+	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/ratelimit"
+	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/storage"
+	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/tracing"
+	"github.com/timur-harin/sum25-go-flutter-course/backend/migrations"
+	"go.opentelemetry.io/otel/trace"
 )
 
 func main() {
-	// Load configuration
-	cfg := config.Load()
+	// Load configuration: config file, then environment, then flags
+	cfg, printConfig, err := loadConfig()
+	if err != nil {
+		log.Fatalf("❌ %v", err)
+	}
+	if printConfig {
+		cfg.Print(os.Stdout)
+		return
+	}
+	if err := cfg.Validate(); err != nil {
+		log.Fatalf("❌ %v", err)
+	}
+
+	// Settings tagged reload:"live" can change on SIGHUP without a restart
+	live := config.NewLive(cfg, func() (*config.Config, error) {
+		cfg, _, err := loadConfig()
+		return cfg, err
+	})
+
+	// Connections are opened on first use, so the server starts while
+	// the database is down and readiness reports it
+	db, err := storage.Open(cfg.DatabaseURL, poolConfig(cfg))
+	if err != nil {
+		log.Fatalf("❌ Invalid DATABASE_URL: %v", err)
+	}
+	defer db.Close()
+	store := storage.NewPostgres(db)
+
+	all, err := migrate.Load(migrations.FS)
+	if err != nil {
+		log.Fatalf("❌ Failed to load migrations: %v", err)
+	}
+	migrator := migrate.New(db, all)
+	migrator.Locker = migrate.PostgresLock{}
+	migrator.Config = cfg
+
+	// Apply pending migrations before accepting traffic
+	if cfg.AutoMigrate {
+		if err := runMigrations(migrator); err != nil {
+			log.Fatalf("Failed to run migrations: %v", err)
+		}
+	}
+
+	var redisClient *redis.Client
+	if cfg.RateLimit.Store == "redis" {
+		redisClient = newRedisClient(cfg)
+		defer redisClient.Close()
+	}
+
+	// Readiness checks the dependencies; liveness only the process
+	probes := health.New(cfg.Server.HealthTimeout)
+	probes.Register("postgres", health.Ping(db))
+	probes.Register("migrations", health.CheckerFunc(migrator.Check))
+	if redisClient != nil {
+		probes.Register("redis", health.CheckerFunc(func(ctx context.Context) error {
+			return redisClient.Ping(ctx).Err()
+		}))
+	}
 
 	// Initialize Gin router
 	if cfg.Env == "production" {
@@ -26,25 +102,82 @@ func main() {
 
 	router := gin.New()
 
-	// Add middleware
-	router.Use(gin.Logger())
-	router.Use(gin.Recovery())
-	router.Use(middleware.CORS())
+	// Only honour X-Forwarded-For from known proxies when finding the client IP
+	if err := router.SetTrustedProxies(cfg.TrustedProxies); err != nil {
+		log.Fatalf("❌ Invalid trusted proxies: %v", err)
+	}
+
+	// Structured JSON logs; LOG_LEVEL and the access log settings follow reloads
+	logLevel := new(slog.LevelVar)
+	logLevel.Set(logging.ParseLevel(cfg.LogLevel))
+	logger := logging.New(os.Stdout, logLevel)
+	accessLog := middleware.NewAccessLog(accessLogConfig(cfg))
+	live.Subscribe(func(cfg *config.Config) {
+		logLevel.Set(logging.ParseLevel(cfg.LogLevel))
+		accessLog.SetConfig(accessLogConfig(cfg))
+	})
+
+	// Trace requests and the calls made for them; TRACING_EXPORTER picks
+	// where the spans go
+	exporter, err := tracing.NewExporter(context.Background(), tracingConfig(cfg), os.Stdout)
+	if err != nil {
+		log.Fatalf("❌ Failed to create trace exporter: %v", err)
+	}
+	tracerProvider := tracing.NewProvider(tracingConfig(cfg), exporter)
+
+	// Add middleware, then the metrics route so it runs all of it
+	metricsServer := setupRouter(router, cfg, live, logger, tracerProvider, accessLog)
 
-	// Health check endpoint
+	// Health check endpoints
 	router.GET("/health", handlers.HealthCheck)
+	router.GET("/health/live", handlers.HealthCheck)
+	router.GET("/health/ready", handlers.Ready(probes))
+
+	jwtAuth := middleware.NewJWTAuth(middleware.JWTConfig{
+		Secret:         cfg.JWTSecret,
+		PreviousSecret: cfg.JWTPreviousSecret,
+		Issuer:         cfg.JWTIssuer,
+		Audience:       cfg.JWTAudience,
+		ClockSkew:      cfg.JWTClockSkew,
+	})
 
-	// API routes
-	api := router.Group("/api/v1")
-	{
-		api.GET("/ping", handlers.Ping)
-		// Add more routes as needed
+	// Rate limits: per client IP for the whole API, and per user once
+	// authenticated. Both follow RATE_LIMIT_* on reload.
+	limitStore := newRateLimitStore(redisClient)
+	ipLimit, userLimit := rateLimits(cfg)
+	ipLimiter := middleware.NewRateLimiter("api", limitStore, middleware.ByIP, ipLimit)
+	userLimiter := middleware.NewRateLimiter("user", limitStore, middleware.ByUser, userLimit)
+	live.Subscribe(func(cfg *config.Config) {
+		ipLimit, userLimit := rateLimits(cfg)
+		ipLimiter.SetLimit(ipLimit)
+		userLimiter.SetLimit(userLimit)
+	})
+
+	// Browsers post Content-Security-Policy violations here
+	if path := cfg.Security.CSPReportPath; path != "" {
+		router.POST(path, ipLimiter.Handler(), middleware.BodyLimit(64<<10), handlers.CSPReport)
 	}
 
+	// Accounts and tokens. Login attempts are throttled per email in the
+	// rate limit store and refresh tokens in Postgres, so both are shared
+	// between instances.
+	authService := auth.NewService(store.Users(), auth.NewPostgresTokens(db), jwtAuth, limitStore, authConfig(cfg))
+	registerAPI(router, cfg, apiDeps{
+		jwtAuth:     jwtAuth,
+		ipLimiter:   ipLimiter,
+		userLimiter: userLimiter,
+		auth:        authService,
+		idempotency: idempotency.NewMemoryStore(),
+	})
+
 	// Create HTTP server
 	server := &http.Server{
-		Addr:    ":" + cfg.Port,
-		Handler: router,
+		Addr:              ":" + cfg.Port,
+		Handler:           router,
+		ReadHeaderTimeout: cfg.Server.ReadHeaderTimeout,
+		ReadTimeout:       cfg.Server.ReadTimeout,
+		WriteTimeout:      cfg.Server.WriteTimeout,
+		IdleTimeout:       cfg.Server.IdleTimeout,
 	}
 
 	// Start server in a goroutine
@@ -55,19 +188,277 @@ func main() {
 		}
 	}()
 
+	// Reload configuration on SIGHUP or when the config file changes
+	watchCtx, stopWatching := context.WithCancel(context.Background())
+	defer stopWatching()
+	if file := cfg.File(); file != "" {
+		live.WatchFile(watchCtx, file, 5*time.Second, logReload)
+	}
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	go func() {
+		for range hup {
+			log.Println("🔄 Reloading configuration...")
+			logReload(live.Reload())
+		}
+	}()
+
 	// Wait for interrupt signal to gracefully shutdown the server
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 	<-quit
 	log.Println("🛑 Shutting down server...")
 
-	// Give outstanding requests 10 seconds to complete
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	// Fail readiness first so load balancers stop sending new requests
+	probes.ShutDown()
+	if delay := cfg.Server.ShutdownDelay; delay > 0 {
+		log.Printf("⏳ Draining for %s...", delay)
+		time.Sleep(delay)
+	}
+
+	// Give outstanding requests SERVER_SHUTDOWN_TIMEOUT to complete
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.Server.ShutdownTimeout)
 	defer cancel()
 
 	if err := server.Shutdown(ctx); err != nil {
 		log.Fatalf("Server forced to shutdown: %v", err)
 	}
+	if metricsServer != nil {
+		metricsServer.Shutdown(ctx)
+	}
+	if err := tracerProvider.Shutdown(ctx); err != nil {
+		log.Printf("⚠️  Failed to flush traces: %v", err)
+	}
 
 	log.Println("✅ Server exited")
 }
+
+// loadConfig reads the configuration from all layers. It is called again
+// on every reload, so flags are parsed into a fresh FlagSet each time.
+func loadConfig() (*config.Config, bool, error) {
+	flags := flag.NewFlagSet("server", flag.ExitOnError)
+	printConfig := flags.Bool("print-config", false, "print the merged configuration with secrets redacted and exit")
+
+	cfg, err := config.LoadWithFlags(flags, os.Args[1:])
+	return cfg, *printConfig, err
+}
+
+func logReload(result config.ReloadResult, err error) {
+	if err != nil {
+		log.Printf("❌ Configuration reload failed, keeping the current settings: %v", err)
+		return
+	}
+	if len(result.Applied) > 0 {
+		log.Printf("✅ Configuration reloaded: %s", strings.Join(result.Applied, ", "))
+	}
+	if len(result.RestartRequired) > 0 {
+		log.Printf("⚠️  Restart required to apply: %s", strings.Join(result.RestartRequired, ", "))
+	}
+}
+
+// setupRouter adds the middleware every route runs and the metrics route,
+// or starts the metrics listener when METRICS_ADDR is set
+func setupRouter(router *gin.Engine, cfg *config.Config, live *config.Live, logger *slog.Logger,
+	tracerProvider trace.TracerProvider, accessLog *middleware.AccessLog) *http.Server {
+	router.Use(middleware.RequestID(logger))
+	router.Use(middleware.Tracing(tracerProvider))
+	router.Use(accessLog.Handler())
+	var registry *prometheus.Registry
+	if cfg.Metrics.Enabled {
+		registry = metrics.NewRegistry()
+		router.Use(middleware.NewHTTPMetrics(registry).Handler())
+	}
+	if cfg.Compress.Enabled {
+		compressConfig := middleware.DefaultCompressConfig()
+		compressConfig.Encodings = cfg.Compress.Encodings
+		compressConfig.MinSize = cfg.Compress.MinSize
+		router.Use(middleware.Compress(compressConfig))
+	}
+	router.Use(middleware.Recovery(errorreport.NewJSONReporter(os.Stderr)))
+	// Before CORS, which answers preflights and rejected origins itself
+	router.Use(middleware.SecurityHeaders(securityConfig(cfg)))
+	corsConfig := middleware.DefaultCORSConfig(cfg.CORSOrigins)
+	corsConfig.ExposedHeaders = cfg.CORSExposed
+	corsConfig.MaxAge = cfg.CORSMaxAge
+	cors := middleware.NewCORS(corsConfig)
+	live.Subscribe(func(cfg *config.Config) { cors.SetOrigins(cfg.CORSOrigins) })
+	router.Use(cors.Handler())
+	// Handlers check flags with middleware.FeatureEnabled
+	router.Use(middleware.Features(func() []string { return live.Current().Features }))
+
+	// Gin fixes a route's middleware when the route is added, so the
+	// metrics route must come after every router.Use
+	if registry == nil {
+		return nil
+	}
+	return serveMetrics(router, cfg, metrics.Handler(registry))
+}
+
+// apiDeps are the services the API routes use
+type apiDeps struct {
+	jwtAuth     *middleware.JWTAuth
+	ipLimiter   *middleware.RateLimiter
+	userLimiter *middleware.RateLimiter
+	auth        *auth.Service
+	idempotency idempotency.Store
+}
+
+// registerAPI adds the /api/v1 routes. POST and PATCH requests with an
+// Idempotency-Key are answered once per user, or per client IP before
+// sign-in.
+func registerAPI(router *gin.Engine, cfg *config.Config, deps apiDeps) {
+	// GET responses carry weak ETags so polling clients get 304s
+	api := router.Group("/api/v1",
+		deps.ipLimiter.Handler(),
+		middleware.BodyLimit(cfg.Server.MaxBodyBytes),
+		middleware.Timeout(cfg.Server.RequestTimeout),
+		middleware.ETag(),
+	)
+	users := api.Group("/users")
+	// Routes below require a valid bearer token; attach
+	// middleware.RequireRole or RequireScope to narrow access
+	authed := api.Group("", deps.jwtAuth.Handler(), deps.userLimiter.Handler())
+	if cfg.Idempotency.Enabled {
+		users.Use(middleware.NewIdempotency(deps.idempotency, middleware.ByIP, cfg.Idempotency.TTL).Handler())
+		authed.Use(middleware.NewIdempotency(deps.idempotency, middleware.ByUser, cfg.Idempotency.TTL).Handler())
+	}
+
+	api.GET("/ping", handlers.Ping)
+
+	authHandlers := handlers.NewAuth(deps.auth)
+	users.POST("/register", authHandlers.Register)
+	users.POST("/login", authHandlers.Login)
+	users.POST("/refresh", authHandlers.Refresh)
+	users.POST("/logout", authHandlers.Logout)
+
+	authed.GET("/me", handlers.Me)
+	// Add more routes as needed
+}
+
+// serveMetrics exposes the metrics on the API router, or on a listener of
+// their own when METRICS_ADDR is set so they can be kept off the public
+// port. It returns the separate server, if any.
+func serveMetrics(router *gin.Engine, cfg *config.Config, handler http.Handler) *http.Server {
+	if cfg.Metrics.Addr == "" {
+		router.GET(cfg.Metrics.Path, gin.WrapH(handler))
+		return nil
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle(cfg.Metrics.Path, handler)
+	server := &http.Server{Addr: cfg.Metrics.Addr, Handler: mux, ReadHeaderTimeout: cfg.Server.ReadHeaderTimeout}
+	go func() {
+		log.Printf("📈 Metrics listening on %s%s", cfg.Metrics.Addr, cfg.Metrics.Path)
+		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			log.Fatalf("Failed to start metrics server: %v", err)
+		}
+	}()
+	return server
+}
+
+func securityConfig(cfg *config.Config) middleware.SecurityConfig {
+	security := middleware.DefaultSecurityConfig()
+	security.HSTSMaxAge = cfg.Security.HSTSMaxAge
+	security.HSTSIncludeSubdomains = cfg.Security.HSTSIncludeSubdomains
+	security.HSTSPreload = cfg.Security.HSTSPreload
+	security.TrustedProxies = cfg.TrustedProxies
+	security.FrameOptions = cfg.Security.FrameOptions
+	security.ReferrerPolicy = cfg.Security.ReferrerPolicy
+	security.PermissionsPolicy = cfg.Security.PermissionsPolicy
+	security.CSPReportOnly = cfg.Security.CSPReportOnly
+	if path := cfg.Security.CSPReportPath; path != "" {
+		security.CSP.Set(csp.ReportURI, csp.Source(path))
+	}
+	return security
+}
+
+func poolConfig(cfg *config.Config) storage.PoolConfig {
+	return storage.PoolConfig{
+		MaxOpenConns:    cfg.Database.MaxOpenConns,
+		MaxIdleConns:    cfg.Database.MaxIdleConns,
+		ConnMaxLifetime: cfg.Database.ConnMaxLifetime,
+		ConnMaxIdleTime: cfg.Database.ConnMaxIdleTime,
+	}
+}
+
+func authConfig(cfg *config.Config) auth.Config {
+	authCfg := auth.Config{
+		AccessTTL:  cfg.Auth.AccessTTL,
+		RefreshTTL: cfg.Auth.RefreshTTL,
+		BcryptCost: cfg.Auth.BcryptCost,
+	}
+	if cfg.Auth.LoginAttempts > 0 {
+		authCfg.LoginLimit = ratelimit.Limit{
+			Rate:  float64(cfg.Auth.LoginAttempts) / cfg.Auth.LoginWindow.Seconds(),
+			Burst: cfg.Auth.LoginAttempts,
+		}
+	}
+	return authCfg
+}
+
+func tracingConfig(cfg *config.Config) tracing.Config {
+	return tracing.Config{
+		Exporter:       cfg.Tracing.Exporter,
+		Endpoint:       cfg.Tracing.OTLPEndpoint,
+		SampleRatio:    cfg.Tracing.SampleRatio,
+		ServiceName:    cfg.Tracing.ServiceName,
+		ServiceVersion: buildinfo.Get().Version,
+	}
+}
+
+func accessLogConfig(cfg *config.Config) middleware.AccessLogConfig {
+	return middleware.AccessLogConfig{
+		SampleRate: cfg.AccessLog.SampleRate,
+		SkipPaths:  cfg.AccessLog.SkipPaths,
+	}
+}
+
+// newRedisClient connects to REDIS_URL, tracing each command
+func newRedisClient(cfg *config.Config) *redis.Client {
+	opts, err := redis.ParseURL(cfg.RedisURL)
+	if err != nil {
+		log.Fatalf("❌ Invalid REDIS_URL: %v", err)
+	}
+	client := redis.NewClient(opts)
+	client.AddHook(tracing.RedisHook{})
+	return client
+}
+
+// newRateLimitStore returns a Redis store, which shares the limits between
+// instances, when RATE_LIMIT_STORE selected Redis and a memory store
+// otherwise
+func newRateLimitStore(client *redis.Client) ratelimit.Store {
+	if client == nil {
+		return ratelimit.NewMemoryStore()
+	}
+	return ratelimit.NewRedisStore(client)
+}
+
+// rateLimits returns the per-IP and per-user limits, unlimited when rate
+// limiting is disabled
+func rateLimits(cfg *config.Config) (ip, user ratelimit.Limit) {
+	if !cfg.RateLimit.Enabled {
+		return ratelimit.Limit{}, ratelimit.Limit{}
+	}
+	return ratelimit.Limit{Rate: cfg.RateLimit.RPS, Burst: cfg.RateLimit.Burst},
+		ratelimit.Limit{Rate: cfg.RateLimit.UserRPS, Burst: cfg.RateLimit.UserBurst}
+}
+
+// runMigrations applies the migrations embedded in the binary. The advisory
+// lock makes it safe for several instances to start at the same time.
+func runMigrations(m *migrate.Migrator) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+
+	log.Println("🔄 Applying pending migrations...")
+	err := m.Up(ctx, 0)
+	if errors.Is(err, migrate.ErrNoChange) {
+		log.Println("✅ Database schema is up to date")
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	log.Println("✅ Migrations completed successfully")
+	return nil
+}
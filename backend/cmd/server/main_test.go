@@ -0,0 +1,121 @@
+package main
+
+import (
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/auth"
+	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/config"
+	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/idempotency"
+	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/logging"
+	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/middleware"
+	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/ratelimit"
+	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/storage"
+	"go.opentelemetry.io/otel/trace/noop"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// newTestRouter returns a router with the server's middleware and default
+// configuration, and the metrics server, if any
+func newTestRouter(t *testing.T) (*gin.Engine, *http.Server, *config.Config) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	cfg := config.Load()
+	live := config.NewLive(cfg, func() (*config.Config, error) { return cfg, nil })
+
+	router := gin.New()
+	metricsServer := setupRouter(router, cfg, live, logging.New(io.Discard, new(slog.LevelVar)),
+		noop.NewTracerProvider(), middleware.NewAccessLog(accessLogConfig(cfg)))
+	return router, metricsServer, cfg
+}
+
+func TestMetricsRouteRunsTheMiddleware(t *testing.T) {
+	router, metricsServer, cfg := newTestRouter(t)
+	if metricsServer != nil {
+		t.Fatal("Expected the metrics on the API router by default")
+	}
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, cfg.Metrics.Path, nil))
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", w.Code)
+	}
+	for _, header := range []string{"X-Request-ID", "X-Content-Type-Options", "Content-Security-Policy"} {
+		if w.Header().Get(header) == "" {
+			t.Errorf("Expected %s on the metrics response", header)
+		}
+	}
+}
+
+func TestCORSRejectionsCarrySecurityHeaders(t *testing.T) {
+	router, _, _ := newTestRouter(t)
+	router.POST("/api/v1/ping", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	req := httptest.NewRequest(http.MethodOptions, "/api/v1/ping", nil)
+	req.Header.Set("Origin", "https://evil.example.com")
+	req.Header.Set("Access-Control-Request-Method", http.MethodPost)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusForbidden {
+		t.Fatalf("Expected the preflight to be rejected, got %d", w.Code)
+	}
+	for _, header := range []string{"X-Content-Type-Options", "X-Frame-Options", "Content-Security-Policy"} {
+		if w.Header().Get(header) == "" {
+			t.Errorf("Expected %s on the rejected preflight", header)
+		}
+	}
+}
+
+// newTestAPI returns a router with the API routes on in-memory stores
+func newTestAPI(t *testing.T) *gin.Engine {
+	t.Helper()
+	router, _, cfg := newTestRouter(t)
+	cfg.Auth.BcryptCost = bcrypt.MinCost
+
+	jwtAuth := middleware.NewJWTAuth(middleware.JWTConfig{Secret: cfg.JWTSecret, Issuer: cfg.JWTIssuer})
+	limitStore := ratelimit.NewMemoryStore()
+	ipLimit, userLimit := rateLimits(cfg)
+	registerAPI(router, cfg, apiDeps{
+		jwtAuth:     jwtAuth,
+		ipLimiter:   middleware.NewRateLimiter("api", limitStore, middleware.ByIP, ipLimit),
+		userLimiter: middleware.NewRateLimiter("user", limitStore, middleware.ByUser, userLimit),
+		auth:        auth.NewService(storage.NewMemory().Users(), auth.NewMemoryTokens(), jwtAuth, limitStore, authConfig(cfg)),
+		idempotency: idempotency.NewMemoryStore(),
+	})
+	return router
+}
+
+func TestRegisterIsIdempotent(t *testing.T) {
+	router := newTestAPI(t)
+	register := func() *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/users/register",
+			strings.NewReader(`{"email":"alice@example.com","password":"correct horse battery"}`))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set(middleware.IdempotencyKeyHeader, "register-1")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	first := register()
+	if first.Code != http.StatusCreated {
+		t.Fatalf("Expected status 201, got %d: %s", first.Code, first.Body)
+	}
+	retry := register()
+	if retry.Code != http.StatusCreated {
+		t.Errorf("Expected the retry to replay 201 instead of a conflict, got %d: %s", retry.Code, retry.Body)
+	}
+	if retry.Header().Get(middleware.IdempotentReplayedHeader) != "true" {
+		t.Errorf("Expected %s: true on the retry", middleware.IdempotentReplayedHeader)
+	}
+	if retry.Body.String() != first.Body.String() {
+		t.Errorf("Expected the retry to get the first body, got %s", retry.Body)
+	}
+}
@@ -0,0 +1,35 @@
+package apierror
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/logging"
+)
+
+// Body is the JSON error envelope returned by every endpoint:
+//
+//	{"error": {"code": "forbidden", "message": "origin not allowed", "request_id": "..."}}
+type Body struct {
+	Error Detail `json:"error"`
+}
+
+// Detail describes a single error. Code is a stable, machine-readable
+// identifier; Message is meant for humans and may change.
+type Detail struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	// RequestID lets clients quote the failing request to support
+	RequestID string `json:"request_id,omitempty"`
+}
+
+// New builds an error envelope
+func New(code, message string) Body {
+	return Body{Error: Detail{Code: code, Message: message}}
+}
+
+// Abort stops the handler chain and writes the error envelope, including
+// the request ID when there is one
+func Abort(c *gin.Context, status int, code, message string) {
+	body := New(code, message)
+	body.Error.RequestID = logging.RequestID(c.Request.Context())
+	c.AbortWithStatusJSON(status, body)
+}
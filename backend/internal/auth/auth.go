@@ -0,0 +1,264 @@
+// Package auth registers users and issues their tokens: short-lived access
+// JWTs and rotating refresh tokens with reuse detection
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"net/mail"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/middleware"
+	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/ratelimit"
+	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/storage"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Password length limits; bcrypt ignores bytes past 72
+const (
+	MinPasswordLength = 8
+	MaxPasswordLength = 72
+)
+
+// DefaultRole is granted to every registered user
+const DefaultRole = "user"
+
+var (
+	// ErrInvalidEmail is returned for addresses that don't parse
+	ErrInvalidEmail = errors.New("invalid email address")
+	// ErrInvalidPassword is returned for passwords outside the length limits
+	ErrInvalidPassword = fmt.Errorf("password must be %d to %d bytes", MinPasswordLength, MaxPasswordLength)
+	// ErrInvalidCredentials is returned for an unknown email or wrong password
+	ErrInvalidCredentials = errors.New("invalid email or password")
+	// ErrEmailTaken is returned when registering an email that is in use
+	ErrEmailTaken = errors.New("email already registered")
+)
+
+// ThrottledError is returned by Login after too many attempts for an email
+type ThrottledError struct {
+	RetryAfter time.Duration
+}
+
+func (e *ThrottledError) Error() string {
+	return fmt.Sprintf("too many login attempts, retry in %s", e.RetryAfter)
+}
+
+// Signer issues access tokens; *middleware.JWTAuth implements it
+type Signer interface {
+	Sign(claims *middleware.Claims) (string, error)
+}
+
+// Config holds the token lifetimes and password and login settings
+type Config struct {
+	AccessTTL  time.Duration
+	RefreshTTL time.Duration
+	// BcryptCost is the work factor of new password hashes
+	BcryptCost int
+	// LoginLimit bounds login attempts per email; unlimited disables it
+	LoginLimit ratelimit.Limit
+}
+
+// Tokens are issued on login and refresh
+type Tokens struct {
+	AccessToken  string
+	RefreshToken string
+	ExpiresIn    time.Duration
+}
+
+// Service registers and signs in users
+type Service struct {
+	users  storage.UserRepository
+	tokens TokenStore
+	signer Signer
+	limits ratelimit.Store
+	cfg    Config
+	dummy  []byte
+
+	// now is replaced in tests
+	now func() time.Time
+}
+
+// NewService returns a service storing users and refresh tokens in the
+// given stores. Login attempts are counted in limits.
+func NewService(users storage.UserRepository, tokens TokenStore, signer Signer, limits ratelimit.Store, cfg Config) *Service {
+	// Compared against for unknown emails, so they take as long as wrong
+	// passwords and don't reveal which emails are registered
+	dummy, err := bcrypt.GenerateFromPassword([]byte("not a password"), cfg.BcryptCost)
+	if err != nil {
+		panic(err)
+	}
+	return &Service{
+		users:  users,
+		tokens: tokens,
+		signer: signer,
+		limits: limits,
+		cfg:    cfg,
+		dummy:  dummy,
+		now:    time.Now,
+	}
+}
+
+// Register creates a user and signs them in
+func (s *Service) Register(ctx context.Context, email, password, name string) (*storage.User, Tokens, error) {
+	email, err := normalizeEmail(email)
+	if err != nil {
+		return nil, Tokens{}, err
+	}
+	if len(password) < MinPasswordLength || len(password) > MaxPasswordLength {
+		return nil, Tokens{}, ErrInvalidPassword
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), s.cfg.BcryptCost)
+	if err != nil {
+		return nil, Tokens{}, err
+	}
+	user := &storage.User{
+		ID:           uuid.NewString(),
+		Email:        email,
+		Name:         strings.TrimSpace(name),
+		PasswordHash: string(hash),
+		Roles:        []string{DefaultRole},
+		CreatedAt:    s.now().UTC(),
+	}
+	err = s.users.Create(ctx, user)
+	if errors.Is(err, storage.ErrConflict) {
+		return nil, Tokens{}, ErrEmailTaken
+	}
+	if err != nil {
+		return nil, Tokens{}, err
+	}
+
+	tokens, err := s.issue(ctx, user, uuid.NewString())
+	return user, tokens, err
+}
+
+// Login checks the password and starts a new refresh token family. Too
+// many attempts for one email return *ThrottledError.
+func (s *Service) Login(ctx context.Context, email, password string) (Tokens, error) {
+	email, err := normalizeEmail(email)
+	if err != nil {
+		return Tokens{}, ErrInvalidCredentials
+	}
+
+	if !s.cfg.LoginLimit.Unlimited() {
+		result, err := s.limits.Take(ctx, "login:"+email, s.cfg.LoginLimit, s.now())
+		switch {
+		case err != nil:
+			log.Printf("⚠️  Login throttle store failed, allowing attempt: %v", err)
+		case !result.Allowed:
+			return Tokens{}, &ThrottledError{RetryAfter: result.RetryAfter}
+		}
+	}
+
+	user, err := s.users.ByEmail(ctx, email)
+	if errors.Is(err, storage.ErrNotFound) {
+		bcrypt.CompareHashAndPassword(s.dummy, []byte(password))
+		return Tokens{}, ErrInvalidCredentials
+	}
+	if err != nil {
+		return Tokens{}, err
+	}
+	if bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(password)) != nil {
+		return Tokens{}, ErrInvalidCredentials
+	}
+
+	return s.issue(ctx, user, uuid.NewString())
+}
+
+// Refresh exchanges a refresh token for new tokens. Each refresh token
+// works once; presenting one again revokes its whole family, signing out
+// both the thief and the user, and returns ErrTokenUsed.
+func (s *Service) Refresh(ctx context.Context, refreshToken string) (Tokens, error) {
+	token, err := s.tokens.Use(ctx, hashToken(refreshToken), s.now())
+	if errors.Is(err, ErrTokenUsed) {
+		if err := s.tokens.RevokeFamily(ctx, token.Family); err != nil {
+			return Tokens{}, err
+		}
+		return Tokens{}, ErrTokenUsed
+	}
+	if err != nil {
+		return Tokens{}, err
+	}
+
+	user, err := s.users.ByID(ctx, token.UserID)
+	if errors.Is(err, storage.ErrNotFound) {
+		return Tokens{}, ErrTokenNotFound
+	}
+	if err != nil {
+		return Tokens{}, err
+	}
+	return s.issue(ctx, user, token.Family)
+}
+
+// Logout revokes the family of refreshToken, ending that session. Unknown
+// tokens are ignored.
+func (s *Service) Logout(ctx context.Context, refreshToken string) error {
+	token, err := s.tokens.Use(ctx, hashToken(refreshToken), s.now())
+	if errors.Is(err, ErrTokenNotFound) {
+		return nil
+	}
+	if err != nil && !errors.Is(err, ErrTokenUsed) {
+		return err
+	}
+	return s.tokens.RevokeFamily(ctx, token.Family)
+}
+
+// issue signs an access token for user and stores a new refresh token in
+// family
+func (s *Service) issue(ctx context.Context, user *storage.User, family string) (Tokens, error) {
+	now := s.now()
+	access, err := s.signer.Sign(&middleware.Claims{
+		Roles: user.Roles,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   user.ID,
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(s.cfg.AccessTTL)),
+			ID:        uuid.NewString(),
+		},
+	})
+	if err != nil {
+		return Tokens{}, err
+	}
+
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return Tokens{}, err
+	}
+	refresh := base64.RawURLEncoding.EncodeToString(raw)
+	err = s.tokens.Create(ctx, RefreshToken{
+		Hash:      hashToken(refresh),
+		UserID:    user.ID,
+		Family:    family,
+		ExpiresAt: now.Add(s.cfg.RefreshTTL),
+	})
+	if err != nil {
+		return Tokens{}, err
+	}
+
+	return Tokens{AccessToken: access, RefreshToken: refresh, ExpiresIn: s.cfg.AccessTTL}, nil
+}
+
+// hashToken is the stored form of a refresh token. The tokens are random,
+// so a fast hash is enough.
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func normalizeEmail(email string) (string, error) {
+	email = strings.ToLower(strings.TrimSpace(email))
+	addr, err := mail.ParseAddress(email)
+	if err != nil || addr.Address != email {
+		return "", ErrInvalidEmail
+	}
+	return email, nil
+}
@@ -0,0 +1,186 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/middleware"
+	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/ratelimit"
+	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/storage"
+	"golang.org/x/crypto/bcrypt"
+)
+
+var testJWT = middleware.NewJWTAuth(middleware.JWTConfig{
+	Secret:   strings.Repeat("s", 32),
+	Issuer:   "test",
+	Audience: "test-api",
+})
+
+func newTestService(t *testing.T) (*Service, *MemoryTokens) {
+	t.Helper()
+	tokens := NewMemoryTokens()
+	service := NewService(storage.NewMemory().Users(), tokens, testJWT, ratelimit.NewMemoryStore(), Config{
+		AccessTTL:  15 * time.Minute,
+		RefreshTTL: 24 * time.Hour,
+		BcryptCost: bcrypt.MinCost,
+		LoginLimit: ratelimit.Limit{Rate: 1.0 / 60, Burst: 3},
+	})
+	return service, tokens
+}
+
+func TestRegister(t *testing.T) {
+	ctx := context.Background()
+	service, _ := newTestService(t)
+
+	user, tokens, err := service.Register(ctx, "  Alice@Example.com ", "correct horse", "Alice")
+	if err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+	if user.Email != "alice@example.com" || user.PasswordHash == "correct horse" {
+		t.Errorf("Expected a normalized email and hashed password, got %+v", user)
+	}
+
+	claims, err := testJWT.Verify(tokens.AccessToken)
+	if err != nil {
+		t.Fatalf("Expected a valid access token, got %v", err)
+	}
+	if claims.UserID() != user.ID || !claims.HasRole(DefaultRole) {
+		t.Errorf("Expected claims for %s with role %s, got %+v", user.ID, DefaultRole, claims)
+	}
+	if got := claims.ExpiresAt.Sub(claims.IssuedAt.Time); got != 15*time.Minute {
+		t.Errorf("Expected the access token to last 15m, got %s", got)
+	}
+
+	tests := []struct {
+		name     string
+		email    string
+		password string
+		wantErr  error
+	}{
+		{"email taken", "alice@example.com", "another password", ErrEmailTaken},
+		{"email taken in other case", "ALICE@example.com", "another password", ErrEmailTaken},
+		{"invalid email", "alice", "correct horse", ErrInvalidEmail},
+		{"email with display name", "Alice <bob@example.com>", "correct horse", ErrInvalidEmail},
+		{"short password", "bob@example.com", "short", ErrInvalidPassword},
+		{"long password", "bob@example.com", strings.Repeat("p", 73), ErrInvalidPassword},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, _, err := service.Register(ctx, tt.email, tt.password, ""); !errors.Is(err, tt.wantErr) {
+				t.Errorf("Expected %v, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestLogin(t *testing.T) {
+	ctx := context.Background()
+	service, _ := newTestService(t)
+	service.Register(ctx, "alice@example.com", "correct horse", "")
+
+	if _, err := service.Login(ctx, "Alice@example.com", "correct horse"); err != nil {
+		t.Errorf("Expected login to succeed, got %v", err)
+	}
+	if _, err := service.Login(ctx, "alice@example.com", "wrong horse"); !errors.Is(err, ErrInvalidCredentials) {
+		t.Errorf("Expected ErrInvalidCredentials for a wrong password, got %v", err)
+	}
+	if _, err := service.Login(ctx, "bob@example.com", "correct horse"); !errors.Is(err, ErrInvalidCredentials) {
+		t.Errorf("Expected ErrInvalidCredentials for an unknown email, got %v", err)
+	}
+}
+
+func TestLoginThrottling(t *testing.T) {
+	ctx := context.Background()
+	service, _ := newTestService(t)
+	service.Register(ctx, "alice@example.com", "correct horse", "")
+	now := time.Unix(1_700_000_000, 0)
+	service.now = func() time.Time { return now }
+
+	for i := 0; i < 3; i++ {
+		service.Login(ctx, "alice@example.com", "guess")
+	}
+	_, err := service.Login(ctx, "alice@example.com", "correct horse")
+	var throttled *ThrottledError
+	if !errors.As(err, &throttled) || throttled.RetryAfter != time.Minute {
+		t.Fatalf("Expected a throttled error with a 1m retry, got %v", err)
+	}
+	if _, err := service.Login(ctx, "bob@example.com", "guess"); !errors.Is(err, ErrInvalidCredentials) {
+		t.Errorf("Expected other emails not to be throttled, got %v", err)
+	}
+
+	now = now.Add(time.Minute)
+	if _, err := service.Login(ctx, "alice@example.com", "correct horse"); err != nil {
+		t.Errorf("Expected login to work again after the wait, got %v", err)
+	}
+}
+
+func TestRefreshRotation(t *testing.T) {
+	ctx := context.Background()
+	service, _ := newTestService(t)
+	_, first, _ := service.Register(ctx, "alice@example.com", "correct horse", "")
+
+	second, err := service.Refresh(ctx, first.RefreshToken)
+	if err != nil {
+		t.Fatalf("Refresh: %v", err)
+	}
+	if second.RefreshToken == first.RefreshToken {
+		t.Fatal("Expected a new refresh token")
+	}
+	third, err := service.Refresh(ctx, second.RefreshToken)
+	if err != nil {
+		t.Fatalf("Refresh with the rotated token: %v", err)
+	}
+
+	// Replaying an old token signals theft: the whole family is revoked,
+	// including the newest token
+	if _, err := service.Refresh(ctx, first.RefreshToken); !errors.Is(err, ErrTokenUsed) {
+		t.Errorf("Expected ErrTokenUsed for a replayed token, got %v", err)
+	}
+	if _, err := service.Refresh(ctx, third.RefreshToken); !errors.Is(err, ErrTokenNotFound) {
+		t.Errorf("Expected the latest token to be revoked, got %v", err)
+	}
+
+	if _, err := service.Refresh(ctx, "made-up"); !errors.Is(err, ErrTokenNotFound) {
+		t.Errorf("Expected ErrTokenNotFound for an unknown token, got %v", err)
+	}
+}
+
+func TestRefreshExpiry(t *testing.T) {
+	ctx := context.Background()
+	service, _ := newTestService(t)
+	now := time.Unix(1_700_000_000, 0)
+	service.now = func() time.Time { return now }
+	_, tokens, _ := service.Register(ctx, "alice@example.com", "correct horse", "")
+
+	now = now.Add(24 * time.Hour)
+	if _, err := service.Refresh(ctx, tokens.RefreshToken); !errors.Is(err, ErrTokenNotFound) {
+		t.Errorf("Expected an expired token to be rejected, got %v", err)
+	}
+}
+
+func TestLogoutRevokesFamily(t *testing.T) {
+	ctx := context.Background()
+	service, store := newTestService(t)
+	_, phone, _ := service.Register(ctx, "alice@example.com", "correct horse", "")
+	laptop, _ := service.Login(ctx, "alice@example.com", "correct horse")
+	rotated, _ := service.Refresh(ctx, phone.RefreshToken)
+
+	if err := service.Logout(ctx, rotated.RefreshToken); err != nil {
+		t.Fatalf("Logout: %v", err)
+	}
+	if _, err := service.Refresh(ctx, rotated.RefreshToken); !errors.Is(err, ErrTokenNotFound) {
+		t.Errorf("Expected the logged out session to be revoked, got %v", err)
+	}
+	if _, err := service.Refresh(ctx, laptop.RefreshToken); err != nil {
+		t.Errorf("Expected other sessions to stay signed in, got %v", err)
+	}
+	if err := service.Logout(ctx, "made-up"); err != nil {
+		t.Errorf("Expected unknown tokens to be ignored, got %v", err)
+	}
+	if store.Len() != 2 {
+		t.Errorf("Expected only the laptop session's tokens to remain, got %d", store.Len())
+	}
+}
@@ -0,0 +1,134 @@
+package auth
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/tracing"
+	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// PostgresTokens keeps refresh tokens in the refresh_tokens table, so
+// sessions survive restarts and every instance accepts every token
+type PostgresTokens struct {
+	db *sql.DB
+
+	mu        sync.Mutex
+	lastSweep time.Time
+}
+
+// NewPostgresTokens returns a token store using db
+func NewPostgresTokens(db *sql.DB) *PostgresTokens {
+	return &PostgresTokens{db: db}
+}
+
+// Create implements TokenStore
+func (s *PostgresTokens) Create(ctx context.Context, token RefreshToken) (err error) {
+	ctx, span := startQuery(ctx, "INSERT")
+	defer func() { tracing.End(span, err) }()
+
+	_, err = s.db.ExecContext(ctx,
+		"INSERT INTO refresh_tokens (hash, user_id, family, expires_at) VALUES ($1, $2, $3, $4)",
+		token.Hash, token.UserID, token.Family, token.ExpiresAt.UTC())
+	return err
+}
+
+// Use implements TokenStore. The token is claimed with a single UPDATE, so
+// of two concurrent uses only one succeeds.
+func (s *PostgresTokens) Use(ctx context.Context, hash string, now time.Time) (RefreshToken, error) {
+	now = now.UTC()
+	s.sweep(ctx, now)
+
+	token, err := s.use(ctx, hash, now)
+	if !errors.Is(err, sql.ErrNoRows) {
+		return token, err
+	}
+	return s.find(ctx, hash, now)
+}
+
+// use marks an unused, unexpired token as used
+func (s *PostgresTokens) use(ctx context.Context, hash string, now time.Time) (_ RefreshToken, err error) {
+	ctx, span := startQuery(ctx, "UPDATE")
+	defer func() {
+		if errors.Is(err, sql.ErrNoRows) {
+			span.End()
+			return
+		}
+		tracing.End(span, err)
+	}()
+
+	token := RefreshToken{Hash: hash}
+	err = s.db.QueryRowContext(ctx,
+		"UPDATE refresh_tokens SET used_at = $2 WHERE hash = $1 AND used_at IS NULL AND expires_at > $2 RETURNING user_id, family, expires_at",
+		hash, now).Scan(&token.UserID, &token.Family, &token.ExpiresAt)
+	token.ExpiresAt = token.ExpiresAt.UTC()
+	return token, err
+}
+
+// find explains why use claimed nothing: the token is unknown, expired,
+// revoked or already used
+func (s *PostgresTokens) find(ctx context.Context, hash string, now time.Time) (_ RefreshToken, err error) {
+	ctx, span := startQuery(ctx, "SELECT")
+	defer func() {
+		if errors.Is(err, ErrTokenNotFound) || errors.Is(err, ErrTokenUsed) {
+			span.End()
+			return
+		}
+		tracing.End(span, err)
+	}()
+
+	token := RefreshToken{Hash: hash}
+	var usedAt sql.NullTime
+	err = s.db.QueryRowContext(ctx,
+		"SELECT user_id, family, expires_at, used_at FROM refresh_tokens WHERE hash = $1 AND expires_at > $2",
+		hash, now).Scan(&token.UserID, &token.Family, &token.ExpiresAt, &usedAt)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return RefreshToken{}, ErrTokenNotFound
+	case err != nil:
+		return RefreshToken{}, err
+	case usedAt.Valid:
+		token.ExpiresAt = token.ExpiresAt.UTC()
+		return token, ErrTokenUsed
+	}
+	// Created after use looked for it; treat it as unknown
+	return RefreshToken{}, ErrTokenNotFound
+}
+
+// RevokeFamily implements TokenStore
+func (s *PostgresTokens) RevokeFamily(ctx context.Context, family string) (err error) {
+	ctx, span := startQuery(ctx, "DELETE")
+	defer func() { tracing.End(span, err) }()
+
+	_, err = s.db.ExecContext(ctx, "DELETE FROM refresh_tokens WHERE family = $1", family)
+	return err
+}
+
+// sweep deletes expired tokens, at most once per sweepInterval. Failures
+// are left to the next sweep.
+func (s *PostgresTokens) sweep(ctx context.Context, now time.Time) {
+	s.mu.Lock()
+	if now.Sub(s.lastSweep) < sweepInterval {
+		s.mu.Unlock()
+		return
+	}
+	s.lastSweep = now
+	s.mu.Unlock()
+
+	ctx, span := startQuery(ctx, "DELETE")
+	_, err := s.db.ExecContext(ctx, "DELETE FROM refresh_tokens WHERE expires_at <= $1", now)
+	tracing.End(span, err)
+}
+
+// startQuery starts the client span of a refresh_tokens query
+func startQuery(ctx context.Context, operation string) (context.Context, trace.Span) {
+	return tracing.StartClient(ctx, operation+" refresh_tokens",
+		semconv.DBSystemNamePostgreSQL,
+		semconv.DBOperationName(operation),
+		semconv.DBCollectionName("refresh_tokens"),
+	)
+}
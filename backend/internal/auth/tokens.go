@@ -0,0 +1,118 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrTokenNotFound is returned for refresh tokens that were never
+	// issued, have expired or belong to a revoked family
+	ErrTokenNotFound = errors.New("refresh token not found")
+	// ErrTokenUsed is returned when a refresh token is presented a second
+	// time, which means it was stolen or replayed
+	ErrTokenUsed = errors.New("refresh token already used")
+)
+
+// RefreshToken is an issued refresh token. Only a hash of the token is
+// stored. Each refresh replaces the token with a new one of the same
+// family, which starts at login.
+type RefreshToken struct {
+	Hash      string
+	UserID    string
+	Family    string
+	ExpiresAt time.Time
+}
+
+// TokenStore stores refresh tokens. Use must be atomic so a token can only
+// be exchanged once.
+type TokenStore interface {
+	// Create stores a new token
+	Create(ctx context.Context, token RefreshToken) error
+	// Use marks the token with hash as used and returns it. A token used
+	// before is returned with ErrTokenUsed.
+	Use(ctx context.Context, hash string, now time.Time) (RefreshToken, error)
+	// RevokeFamily deletes every token of family
+	RevokeFamily(ctx context.Context, family string) error
+}
+
+// sweepInterval is how often the memory store drops expired tokens
+const sweepInterval = time.Minute
+
+// MemoryTokens keeps refresh tokens in process memory, for tests. Tokens
+// are lost on restart and only work on the instance that issued them.
+type MemoryTokens struct {
+	mu        sync.Mutex
+	tokens    map[string]*memoryToken
+	lastSweep time.Time
+}
+
+type memoryToken struct {
+	RefreshToken
+	used bool
+}
+
+// NewMemoryTokens returns an empty in-memory token store
+func NewMemoryTokens() *MemoryTokens {
+	return &MemoryTokens{tokens: make(map[string]*memoryToken)}
+}
+
+// Create implements TokenStore
+func (s *MemoryTokens) Create(_ context.Context, token RefreshToken) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.tokens[token.Hash] = &memoryToken{RefreshToken: token}
+	return nil
+}
+
+// Use implements TokenStore
+func (s *MemoryTokens) Use(_ context.Context, hash string, now time.Time) (RefreshToken, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if now.Sub(s.lastSweep) >= sweepInterval {
+		s.sweep(now)
+	}
+
+	t, ok := s.tokens[hash]
+	if !ok || !now.Before(t.ExpiresAt) {
+		return RefreshToken{}, ErrTokenNotFound
+	}
+	if t.used {
+		return t.RefreshToken, ErrTokenUsed
+	}
+	t.used = true
+	return t.RefreshToken, nil
+}
+
+// RevokeFamily implements TokenStore
+func (s *MemoryTokens) RevokeFamily(_ context.Context, family string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for hash, t := range s.tokens {
+		if t.Family == family {
+			delete(s.tokens, hash)
+		}
+	}
+	return nil
+}
+
+// Len returns the number of tokens held
+func (s *MemoryTokens) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.tokens)
+}
+
+func (s *MemoryTokens) sweep(now time.Time) {
+	for hash, t := range s.tokens {
+		if !now.Before(t.ExpiresAt) {
+			delete(s.tokens, hash)
+		}
+	}
+	s.lastSweep = now
+}
@@ -0,0 +1,114 @@
+package auth
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/migrate"
+	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/storage"
+	"github.com/timur-harin/sum25-go-flutter-course/backend/migrations"
+	_ "modernc.org/sqlite"
+)
+
+func TestMemoryTokens(t *testing.T) {
+	testTokenStore(t, NewMemoryTokens(), "user-1")
+}
+
+// TestPostgresTokens runs the queries against SQLite with the shipped
+// migrations, like the storage tests
+func TestPostgresTokens(t *testing.T) {
+	db, err := sql.Open("sqlite", ":memory:")
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	// Each connection to :memory: is a separate database
+	db.SetMaxOpenConns(1)
+	defer db.Close()
+
+	all, err := migrate.Load(migrations.FS)
+	if err != nil {
+		t.Fatalf("load migrations: %v", err)
+	}
+	// SQLite only reads columns declared TIMESTAMP back as time.Time
+	for _, m := range all {
+		m.Up = strings.ReplaceAll(m.Up, "TIMESTAMPTZ", "TIMESTAMP")
+	}
+	if err := migrate.New(db, all).Up(context.Background(), 0); err != nil {
+		t.Fatalf("apply migrations: %v", err)
+	}
+	user := &storage.User{Email: "alice@example.com", PasswordHash: "hash"}
+	if err := storage.NewPostgres(db).Users().Create(context.Background(), user); err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+
+	testTokenStore(t, NewPostgresTokens(db), user.ID)
+}
+
+// testTokenStore checks the behaviour every TokenStore must share
+func testTokenStore(t *testing.T, store TokenStore, userID string) {
+	ctx := context.Background()
+	now := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	expires := now.Add(time.Hour)
+
+	for _, token := range []RefreshToken{
+		{Hash: "a", UserID: userID, Family: "f1", ExpiresAt: expires},
+		{Hash: "b", UserID: userID, Family: "f1", ExpiresAt: expires},
+		{Hash: "c", UserID: userID, Family: "f2", ExpiresAt: expires},
+		{Hash: "d", UserID: userID, Family: "f3", ExpiresAt: now.Add(time.Minute)},
+	} {
+		if err := store.Create(ctx, token); err != nil {
+			t.Fatalf("Create %s: %v", token.Hash, err)
+		}
+	}
+
+	token, err := store.Use(ctx, "a", now)
+	if err != nil {
+		t.Fatalf("Use: %v", err)
+	}
+	if token.UserID != userID || token.Family != "f1" || !token.ExpiresAt.Equal(expires) {
+		t.Errorf("Unexpected token %+v", token)
+	}
+
+	token, err = store.Use(ctx, "a", now)
+	if !errors.Is(err, ErrTokenUsed) || token.Family != "f1" {
+		t.Errorf("Expected ErrTokenUsed with the family, got %+v, %v", token, err)
+	}
+	if _, err := store.Use(ctx, "unknown", now); !errors.Is(err, ErrTokenNotFound) {
+		t.Errorf("Expected ErrTokenNotFound for an unknown token, got %v", err)
+	}
+	if _, err := store.Use(ctx, "d", now.Add(2*time.Minute)); !errors.Is(err, ErrTokenNotFound) {
+		t.Errorf("Expected ErrTokenNotFound for an expired token, got %v", err)
+	}
+
+	if err := store.RevokeFamily(ctx, "f1"); err != nil {
+		t.Fatalf("RevokeFamily: %v", err)
+	}
+	if _, err := store.Use(ctx, "b", now); !errors.Is(err, ErrTokenNotFound) {
+		t.Errorf("Expected a revoked token to be not found, got %v", err)
+	}
+
+	// Of concurrent uses of one token, exactly one wins
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	wins := 0
+	for range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := store.Use(ctx, "c", now); err == nil {
+				mu.Lock()
+				wins++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	if wins != 1 {
+		t.Errorf("Expected one successful use, got %d", wins)
+	}
+}
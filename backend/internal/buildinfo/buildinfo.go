@@ -0,0 +1,43 @@
+// Package buildinfo holds the version of the running binary. Release builds
+// set it with the linker:
+//
+//	go build -ldflags "-X github.com/timur-harin/sum25-go-flutter-course/backend/internal/buildinfo.Version=v1.2.0 \
+//	  -X github.com/timur-harin/sum25-go-flutter-course/backend/internal/buildinfo.Commit=$(git rev-parse HEAD) \
+//	  -X github.com/timur-harin/sum25-go-flutter-course/backend/internal/buildinfo.BuildTime=$(date -u +%Y-%m-%dT%H:%M:%SZ)"
+//
+// Without them the commit and time recorded by the Go toolchain are used
+// when available.
+package buildinfo
+
+import "runtime/debug"
+
+// Set with -ldflags -X
+var (
+	Version   = "dev"
+	Commit    = ""
+	BuildTime = ""
+)
+
+// Info describes the running binary
+type Info struct {
+	Version   string
+	Commit    string
+	BuildTime string
+}
+
+// Get returns the injected build information, completed from the VCS
+// stamp of the Go toolchain
+func Get() Info {
+	info := Info{Version: Version, Commit: Commit, BuildTime: BuildTime}
+	if bi, ok := debug.ReadBuildInfo(); ok {
+		for _, s := range bi.Settings {
+			switch {
+			case s.Key == "vcs.revision" && info.Commit == "":
+				info.Commit = s.Value
+			case s.Key == "vcs.time" && info.BuildTime == "":
+				info.BuildTime = s.Value
+			}
+		}
+	}
+	return info
+}
@@ -0,0 +1,13 @@
+package buildinfo
+
+import "testing"
+
+func TestGetPrefersInjectedValues(t *testing.T) {
+	defer func(v, c, b string) { Version, Commit, BuildTime = v, c, b }(Version, Commit, BuildTime)
+	Version, Commit, BuildTime = "v2.0.0", "deadbeef", "2025-07-01T12:00:00Z"
+
+	info := Get()
+	if info != (Info{Version: "v2.0.0", Commit: "deadbeef", BuildTime: "2025-07-01T12:00:00Z"}) {
+		t.Errorf("Expected the injected build info, got %+v", info)
+	}
+}
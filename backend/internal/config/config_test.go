@@ -1,8 +1,11 @@
 package config
 
 import (
+	"errors"
 	"os"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestLoad(t *testing.T) {
@@ -50,36 +53,138 @@ func TestLoadWithEnvVars(t *testing.T) {
 	}
 }
 
-func TestGetEnvAsInt(t *testing.T) {
-	// Test with valid integer
-	os.Setenv("TEST_INT", "42")
-	defer os.Unsetenv("TEST_INT")
+func TestLoadAutoMigrate(t *testing.T) {
+	if cfg := Load(); cfg.AutoMigrate {
+		t.Error("Expected auto-migrate to be disabled by default")
+	}
+
+	os.Setenv("AUTO_MIGRATE", "true")
+	defer os.Unsetenv("AUTO_MIGRATE")
+
+	if cfg := Load(); !cfg.AutoMigrate {
+		t.Error("Expected AUTO_MIGRATE=true to enable auto-migrate")
+	}
+}
+
+func TestValidateDefaults(t *testing.T) {
+	if err := Load().Validate(); err != nil {
+		t.Errorf("Expected development defaults to be valid, got %v", err)
+	}
+}
+
+func TestValidateReportsAllProblems(t *testing.T) {
+	cfg := &Config{
+		Env:         "production",
+		Port:        "99999",
+		DatabaseURL: defaultDatabaseURL,
+		JWTSecret:   defaultJWTSecret,
+		CORSOrigins: []string{"http://localhost:3000", "ftp://files.example.com", "https://app.example.com/path"},
+	}
 
-	result := getEnvAsInt("TEST_INT", 10)
-	if result != 42 {
-		t.Errorf("Expected 42, got %d", result)
+	err := cfg.Validate()
+	var verr *ValidationError
+	if !errors.As(err, &verr) {
+		t.Fatalf("Expected a ValidationError, got %v", err)
 	}
 
-	// Test with fallback
-	result = getEnvAsInt("NON_EXISTENT", 10)
-	if result != 10 {
-		t.Errorf("Expected fallback value 10, got %d", result)
+	// port, two origins, JWT secret and database URL
+	if len(verr.Problems) != 5 {
+		t.Errorf("Expected 5 problems, got %d: %v", len(verr.Problems), verr.Problems)
 	}
 }
 
-func TestGetEnvAsBool(t *testing.T) {
-	// Test with valid boolean
-	os.Setenv("TEST_BOOL", "true")
-	defer os.Unsetenv("TEST_BOOL")
+func TestValidate(t *testing.T) {
+	valid := func() *Config {
+		return &Config{
+			Env:         "production",
+			Port:        "8080",
+			DatabaseURL: "postgres://app@db.internal:5432/app",
+			JWTSecret:   strings.Repeat("s", minJWTSecretLength),
+			CORSOrigins: []string{"https://app.example.com", "https://*.example.com"},
+		}
+	}
 
-	result := getEnvAsBool("TEST_BOOL", false)
-	if result != true {
-		t.Errorf("Expected true, got %v", result)
+	tests := []struct {
+		name    string
+		mutate  func(c *Config)
+		wantErr bool
+	}{
+		{"valid production", func(c *Config) {}, false},
+		{"unknown env", func(c *Config) { c.Env = "prod" }, true},
+		{"non-numeric port", func(c *Config) { c.Port = "http" }, true},
+		{"zero port", func(c *Config) { c.Port = "0" }, true},
+		{"short secret in production", func(c *Config) { c.JWTSecret = "short" }, true},
+		{"short secret in development", func(c *Config) { c.Env = "development"; c.JWTSecret = "short" }, false},
+		{"short previous secret in production", func(c *Config) { c.JWTPreviousSecret = "old" }, true},
+		{"negative clock skew", func(c *Config) { c.JWTClockSkew = -time.Second }, true},
+		{"unknown rate limit store", func(c *Config) { c.RateLimit.Store = "memcached" }, true},
+		{"redis store", func(c *Config) { c.RateLimit.Store = "redis"; c.RedisURL = "redis://cache:6379/0" }, false},
+		{"redis store with bad URL", func(c *Config) { c.RateLimit.Store = "redis"; c.RedisURL = "http://cache" }, true},
+		{"user rate limit disabled", func(c *Config) { c.RateLimit = RateLimitConfig{Enabled: true, RPS: 1, Burst: 1} }, true},
+		{"access log sampling", func(c *Config) { c.AccessLog.SampleRate = 0.1 }, false},
+		{"access log sample rate above 1", func(c *Config) { c.AccessLog.SampleRate = 1.5 }, true},
+		{"relative metrics path", func(c *Config) { c.Metrics = MetricsConfig{Enabled: true, Path: "metrics"} }, true},
+		{"unknown compression encoding", func(c *Config) { c.Compress.Encodings = []string{"gzip", "lzma"} }, true},
+		{"negative server timeout", func(c *Config) { c.Server.IdleTimeout = -time.Second }, true},
+		{"request timeout beyond write timeout", func(c *Config) { c.Server = ServerConfig{RequestTimeout: time.Minute, WriteTimeout: time.Second} }, true},
+		{"unknown frame options", func(c *Config) { c.Security.FrameOptions = "ALLOW-FROM https://a.example.com" }, true},
+		{"relative CSP report path", func(c *Config) { c.Security.CSPReportPath = "csp" }, true},
+		{"idempotency without TTL", func(c *Config) { c.Idempotency = IdempotencyConfig{Enabled: true} }, true},
+		{"OTLP tracing", func(c *Config) {
+			c.Tracing = TracingConfig{Exporter: "otlp", OTLPEndpoint: "http://collector:4318", SampleRatio: 0.5}
+		}, false},
+		{"unknown trace exporter", func(c *Config) { c.Tracing.Exporter = "jaeger" }, true},
+		{"trace sample ratio above 1", func(c *Config) { c.Tracing.SampleRatio = 2 }, true},
+		{"OTLP endpoint without scheme", func(c *Config) { c.Tracing.OTLPEndpoint = "collector:4318" }, true},
+		{"access token outliving refresh token", func(c *Config) { c.Auth = AuthConfig{AccessTTL: time.Hour, RefreshTTL: time.Minute} }, true},
+		{"weak bcrypt cost", func(c *Config) { c.Auth.BcryptCost = 4 }, true},
+		{"login throttling without window", func(c *Config) { c.Auth.LoginAttempts = 5 }, true},
+		{"connection pool", func(c *Config) {
+			c.Database = DatabaseConfig{MaxOpenConns: 10, MaxIdleConns: 10, ConnMaxLifetime: time.Hour}
+		}, false},
+		{"more idle than open connections", func(c *Config) { c.Database = DatabaseConfig{MaxOpenConns: 5, MaxIdleConns: 10} }, true},
+		{"negative connection lifetime", func(c *Config) { c.Database.ConnMaxLifetime = -time.Minute }, true},
+		{"trusted proxies", func(c *Config) { c.TrustedProxies = []string{"10.0.0.0/8", "192.168.1.1", "::1"} }, false},
+		{"invalid trusted proxy", func(c *Config) { c.TrustedProxies = []string{"proxy.internal"} }, true},
+		{"unparsable database URL", func(c *Config) { c.DatabaseURL = "postgres://%zz" }, true},
+		{"database URL without host", func(c *Config) { c.DatabaseURL = "postgres:///app" }, true},
+		{"mysql database URL", func(c *Config) { c.DatabaseURL = "mysql://db/app" }, true},
+		{"wildcard origin", func(c *Config) { c.CORSOrigins = []string{"*"} }, false},
+		{"empty origin", func(c *Config) { c.CORSOrigins = []string{"https://a.example.com", ""} }, true},
+		{"origin with query", func(c *Config) { c.CORSOrigins = []string{"https://a.example.com?x=1"} }, true},
 	}
 
-	// Test with fallback
-	result = getEnvAsBool("NON_EXISTENT", false)
-	if result != false {
-		t.Errorf("Expected fallback value false, got %v", result)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := valid()
+			tt.mutate(cfg)
+			err := cfg.Validate()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateReportsUnparsableEnv(t *testing.T) {
+	t.Setenv("AUTO_MIGRATE", "sometimes")
+
+	cfg := Load()
+	if cfg.AutoMigrate {
+		t.Error("Expected an unparsable AUTO_MIGRATE to keep the default")
+	}
+
+	err := cfg.Validate()
+	if err == nil || !strings.Contains(err.Error(), "AUTO_MIGRATE") {
+		t.Errorf("Expected Validate to name AUTO_MIGRATE, got %v", err)
+	}
+}
+
+func TestLoadCORSOrigins(t *testing.T) {
+	t.Setenv("CORS_ORIGINS", "http://localhost:3000, http://localhost:8080,")
+
+	cfg := Load()
+	if len(cfg.CORSOrigins) != 2 || cfg.CORSOrigins[1] != "http://localhost:8080" {
+		t.Errorf("Expected two trimmed origins, got %q", cfg.CORSOrigins)
 	}
 }
@@ -0,0 +1,293 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/pelletier/go-toml/v2"
+	"gopkg.in/yaml.v3"
+)
+
+// Source tells which configuration layer a value came from
+type Source string
+
+const (
+	SourceDefault Source = "default"
+	SourceFile    Source = "file"
+	SourceEnv     Source = "env"
+	// SourceSecretFile marks a value read from the file named by KEY_FILE
+	SourceSecretFile Source = "secret-file"
+	SourceFlag       Source = "flag"
+)
+
+// Settings are keyed by their environment variable name. Config files use
+// the name in lower case (database_url) and flags in kebab case
+// (--database-url).
+func fileKey(f field) string { return strings.ToLower(f.key) }
+func flagName(f field) string {
+	return strings.ReplaceAll(strings.ToLower(f.key), "_", "-")
+}
+
+// newConfig returns a Config with every setting at its default value,
+// along with the settings bound to it
+func newConfig() (*Config, []field) {
+	cfg := &Config{sources: make(map[string]Source)}
+	fields, err := fields(cfg)
+	if err != nil {
+		panic(err)
+	}
+	if errs := applyDefaults(fields, cfg.sources); len(errs) > 0 {
+		panic(errors.Join(errs...))
+	}
+	return cfg, fields
+}
+
+func applyDefaults(fields []field, sources map[string]Source) []error {
+	var errs []error
+	for _, f := range fields {
+		if !f.hasDefault {
+			continue
+		}
+		if err := f.set(f.def); err != nil {
+			errs = append(errs, fmt.Errorf("invalid default: %w", err))
+			continue
+		}
+		sources[f.key] = SourceDefault
+	}
+	return errs
+}
+
+// applyEnv overrides settings from environment variables. Following the
+// Docker/Kubernetes secrets convention, KEY_FILE names a file holding the
+// value of KEY and takes priority over KEY itself.
+func applyEnv(fields []field, sources map[string]Source) []error {
+	var errs []error
+	for _, f := range fields {
+		value, source, err := lookupEnv(f.key)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if source == "" {
+			continue
+		}
+		if err := f.set(value); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		sources[f.key] = source
+	}
+	return errs
+}
+
+// lookupEnv returns the value of key from KEY_FILE or KEY, along with the
+// source it came from, or an empty source if neither is set
+func lookupEnv(key string) (string, Source, error) {
+	if path, ok := os.LookupEnv(key + "_FILE"); ok {
+		value, err := readSecretFile(path)
+		if err != nil {
+			return "", "", &FieldError{Key: key + "_FILE", Value: path, Err: err}
+		}
+		return value, SourceSecretFile, nil
+	}
+	if value, ok := os.LookupEnv(key); ok {
+		return value, SourceEnv, nil
+	}
+	return "", "", nil
+}
+
+// ErrInsecureSecretFile is returned for a secret file that users other than
+// its owner can read
+var ErrInsecureSecretFile = errors.New("secret file is readable by group or others")
+
+// readSecretFile reads a mounted secret, trimming the trailing newline most
+// editors and `echo` add
+func readSecretFile(path string) (string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", err
+	}
+	if info.Mode().Perm()&0o044 != 0 {
+		return "", fmt.Errorf("%w (mode %v), use 0400 or 0600", ErrInsecureSecretFile, info.Mode().Perm())
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(string(data), "\r\n"), nil
+}
+
+// applyFile overrides settings from a YAML (.yaml, .yml) or TOML (.toml) file
+func (c *Config) applyFile(fields []field, path string) []error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return []error{fmt.Errorf("read config file: %w", err)}
+	}
+
+	values := make(map[string]any)
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &values)
+	case ".toml":
+		err = toml.Unmarshal(data, &values)
+	default:
+		return []error{fmt.Errorf("config file %s: unsupported format %q, use .yaml or .toml", path, ext)}
+	}
+	if err != nil {
+		return []error{fmt.Errorf("parse config file %s: %w", path, err)}
+	}
+
+	known := make(map[string]field, len(fields))
+	for _, f := range fields {
+		known[fileKey(f)] = f
+	}
+
+	var errs []error
+	for key, raw := range values {
+		f, ok := known[key]
+		if !ok {
+			errs = append(errs, fmt.Errorf("config file %s: unknown setting %q", path, key))
+			continue
+		}
+		if err := f.set(fileValue(raw)); err != nil {
+			errs = append(errs, fmt.Errorf("config file %s: %w", path, err))
+			continue
+		}
+		c.sources[f.key] = SourceFile
+	}
+	return errs
+}
+
+// fileValue turns a decoded YAML/TOML value into its environment form,
+// so lists can be written natively in files
+func fileValue(raw any) string {
+	if list, ok := raw.([]any); ok {
+		items := make([]string, len(list))
+		for i, item := range list {
+			items[i] = fmt.Sprint(item)
+		}
+		return strings.Join(items, ",")
+	}
+	return fmt.Sprint(raw)
+}
+
+// checkRequired reports required settings that no layer provided
+func checkRequired(fields []field, sources map[string]Source) []error {
+	var errs []error
+	for _, f := range fields {
+		if _, set := sources[f.key]; f.required && !set {
+			errs = append(errs, &FieldError{Key: f.key, Err: ErrRequired})
+		}
+	}
+	return errs
+}
+
+// flagValue validates a command-line flag against a scratch setting and
+// keeps the raw value so it can be applied after the other layers
+type flagValue struct {
+	f   field
+	raw map[string]string
+}
+
+func (v *flagValue) String() string {
+	if v.raw == nil {
+		return ""
+	}
+	return v.f.String()
+}
+
+func (v *flagValue) Set(value string) error {
+	if err := v.f.set(value); err != nil {
+		return err
+	}
+	v.raw[v.f.key] = value
+	return nil
+}
+
+// IsBoolFlag lets boolean settings be passed as a bare --flag
+func (v *flagValue) IsBoolFlag() bool {
+	return v.raw != nil && v.f.isBool()
+}
+
+// LoadWithFlags builds the configuration in layers, each overriding the one
+// before: defaults, an optional config file (--config or CONFIG_FILE),
+// environment variables and finally the command-line flags it registers
+// on fs. Unlike Load it returns every malformed value as an error.
+func LoadWithFlags(fs *flag.FlagSet, args []string) (*Config, error) {
+	configFile := fs.String("config", os.Getenv("CONFIG_FILE"), "path to a YAML or TOML config file")
+
+	_, scratchFields := newConfig()
+	flagged := make(map[string]string)
+	for _, f := range scratchFields {
+		fs.Var(&flagValue{f: f, raw: flagged}, flagName(f), f.usage)
+	}
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+
+	cfg, fields := newConfig()
+	cfg.file = *configFile
+	var errs []error
+	if *configFile != "" {
+		errs = append(errs, cfg.applyFile(fields, *configFile)...)
+	}
+	errs = append(errs, applyEnv(fields, cfg.sources)...)
+	for _, f := range fields {
+		if raw, ok := flagged[f.key]; ok {
+			_ = f.set(raw)
+			cfg.sources[f.key] = SourceFlag
+		}
+	}
+	errs = append(errs, checkRequired(fields, cfg.sources)...)
+
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// Source returns the layer the setting with the given environment variable
+// name came from
+func (c *Config) Source(key string) Source {
+	if src, ok := c.sources[key]; ok {
+		return src
+	}
+	return SourceDefault
+}
+
+// Print writes the merged configuration with secrets redacted
+func (c *Config) Print(w io.Writer) error {
+	fields, err := fields(c)
+	if err != nil {
+		return err
+	}
+	sort.Slice(fields, func(i, j int) bool { return fields[i].key < fields[j].key })
+
+	for _, f := range fields {
+		if _, err := fmt.Fprintf(w, "%s=%s (%s)\n", f.key, redact(f), c.Source(f.key)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// redact hides secret values. URLs keep everything except the password so
+// host and database can still be checked.
+func redact(f field) string {
+	value := f.String()
+	if !f.secret || value == "" {
+		return value
+	}
+	if u, err := url.Parse(value); err == nil && u.Scheme != "" && u.Host != "" {
+		return u.Redacted()
+	}
+	return "<redacted>"
+}
@@ -0,0 +1,141 @@
+package config
+
+import (
+	"context"
+	"maps"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ReloadResult describes what a Reload changed
+type ReloadResult struct {
+	// Applied lists the settings that changed and took effect
+	Applied []string
+	// RestartRequired lists the settings that changed but only take effect
+	// after a restart, such as PORT or DATABASE_URL
+	RestartRequired []string
+}
+
+// Live holds the running configuration. Reload swaps in the settings that
+// are tagged reload:"live" and notifies subscribers; everything else keeps
+// its start-up value until the process restarts.
+type Live struct {
+	load    func() (*Config, error)
+	current atomic.Pointer[Config]
+
+	// mu serializes reloads and guards subscribers
+	mu          sync.Mutex
+	subscribers []func(*Config)
+}
+
+// NewLive wraps the start-up configuration. load is called on every Reload
+// to read the configuration again from all layers.
+func NewLive(cfg *Config, load func() (*Config, error)) *Live {
+	l := &Live{load: load}
+	l.current.Store(cfg)
+	return l
+}
+
+// Current returns the configuration in effect. It is safe to call from any
+// goroutine; the returned Config must be treated as read-only.
+func (l *Live) Current() *Config {
+	return l.current.Load()
+}
+
+// Subscribe registers fn to be called with the new configuration after
+// every reload that changed a live setting
+func (l *Live) Subscribe(fn func(*Config)) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.subscribers = append(l.subscribers, fn)
+}
+
+// Reload reads and validates the configuration again. If it is invalid the
+// running configuration is kept and the error returned.
+func (l *Live) Reload() (ReloadResult, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	var result ReloadResult
+	next, err := l.load()
+	if err != nil {
+		return result, err
+	}
+	if err := next.Validate(); err != nil {
+		return result, err
+	}
+
+	merged := l.Current().clone()
+	mergedFields, err := fields(merged)
+	if err != nil {
+		return result, err
+	}
+	nextFields, err := fields(next)
+	if err != nil {
+		return result, err
+	}
+
+	for i, f := range mergedFields {
+		if f.String() == nextFields[i].String() {
+			continue
+		}
+		if !f.live {
+			result.RestartRequired = append(result.RestartRequired, f.key)
+			continue
+		}
+		f.value.Set(nextFields[i].value)
+		merged.sources[f.key] = next.Source(f.key)
+		result.Applied = append(result.Applied, f.key)
+	}
+
+	if len(result.Applied) > 0 {
+		l.current.Store(merged)
+		for _, fn := range l.subscribers {
+			fn(merged)
+		}
+	}
+	return result, nil
+}
+
+// WatchFile starts a goroutine that reloads whenever the modification time
+// of path changes, checking every interval until ctx is done. Each reload is
+// passed to report. It complements SIGHUP for mounted ConfigMaps and the like.
+func (l *Live) WatchFile(ctx context.Context, path string, interval time.Duration, report func(ReloadResult, error)) {
+	var lastMod time.Time
+	if info, err := os.Stat(path); err == nil {
+		lastMod = info.ModTime()
+	}
+	go l.watch(ctx, path, lastMod, interval, report)
+}
+
+func (l *Live) watch(ctx context.Context, path string, lastMod time.Time, interval time.Duration, report func(ReloadResult, error)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			info, err := os.Stat(path)
+			if err != nil || info.ModTime().Equal(lastMod) {
+				continue
+			}
+			lastMod = info.ModTime()
+			report(l.Reload())
+		}
+	}
+}
+
+// clone returns a copy that can be modified without affecting c
+func (c *Config) clone() *Config {
+	cp := *c
+	cp.sources = maps.Clone(c.sources)
+	if cp.sources == nil {
+		cp.sources = make(map[string]Source)
+	}
+	cp.loadErrs = nil
+	return &cp
+}
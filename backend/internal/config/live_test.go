@@ -0,0 +1,155 @@
+package config
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestLiveReload(t *testing.T) {
+	t.Setenv("CORS_ORIGINS", "https://old.example.com")
+	live := NewLive(Load(), func() (*Config, error) { return Load(), nil })
+
+	var notified []*Config
+	live.Subscribe(func(cfg *Config) { notified = append(notified, cfg) })
+
+	t.Setenv("CORS_ORIGINS", "https://new.example.com")
+	t.Setenv("LOG_LEVEL", "debug")
+	t.Setenv("RATE_LIMIT_RPS", "2.5")
+	t.Setenv("PORT", "9090")
+
+	result, err := live.Reload()
+	if err != nil {
+		t.Fatalf("Reload: %v", err)
+	}
+
+	wantApplied := []string{"CORS_ORIGINS", "LOG_LEVEL", "RATE_LIMIT_RPS"}
+	if !slices.Equal(result.Applied, wantApplied) {
+		t.Errorf("Expected applied %v, got %v", wantApplied, result.Applied)
+	}
+	if !slices.Equal(result.RestartRequired, []string{"PORT"}) {
+		t.Errorf("Expected PORT to require a restart, got %v", result.RestartRequired)
+	}
+
+	cfg := live.Current()
+	if cfg.CORSOrigins[0] != "https://new.example.com" || cfg.LogLevel != "debug" || cfg.RateLimit.RPS != 2.5 {
+		t.Errorf("Expected live settings to change, got %+v", cfg)
+	}
+	if cfg.Port != "8080" {
+		t.Errorf("Expected PORT to keep its start-up value, got %q", cfg.Port)
+	}
+	if len(notified) != 1 || notified[0] != cfg {
+		t.Errorf("Expected subscribers to be notified once with the new config, got %d calls", len(notified))
+	}
+}
+
+func TestLiveReloadKeepsConfigOnError(t *testing.T) {
+	loadErr := errors.New("broken file")
+	start := Load()
+	calls := 0
+	live := NewLive(start, func() (*Config, error) {
+		calls++
+		if calls == 1 {
+			return nil, loadErr
+		}
+		cfg := Load()
+		cfg.LogLevel = "loud"
+		return cfg, nil
+	})
+
+	if _, err := live.Reload(); !errors.Is(err, loadErr) {
+		t.Errorf("Expected load error, got %v", err)
+	}
+	if _, err := live.Reload(); err == nil {
+		t.Error("Expected an invalid config to be rejected")
+	}
+	if live.Current() != start {
+		t.Error("Expected the running config to be kept")
+	}
+}
+
+func TestLiveConcurrentAccess(t *testing.T) {
+	live := NewLive(Load(), func() (*Config, error) { return Load(), nil })
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				_ = live.Current().CORSOrigins
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			if _, err := live.Reload(); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestLiveWatchFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte("log_level: info\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	load := func() (*Config, error) {
+		cfg, _ := newConfig()
+		if errs := cfg.applyFile(mustFields(t, cfg), path); len(errs) > 0 {
+			return nil, errors.Join(errs...)
+		}
+		return cfg, nil
+	}
+	start, err := load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	live := NewLive(start, load)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	reloaded := make(chan ReloadResult, 1)
+	live.WatchFile(ctx, path, 10*time.Millisecond, func(r ReloadResult, err error) {
+		if err != nil {
+			t.Error(err)
+		}
+		reloaded <- r
+	})
+
+	// Make sure the modification time moves even on coarse filesystems
+	future := time.Now().Add(time.Second)
+	if err := os.WriteFile(path, []byte("log_level: warn\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(path, future, future); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case r := <-reloaded:
+		if !slices.Equal(r.Applied, []string{"LOG_LEVEL"}) {
+			t.Errorf("Expected LOG_LEVEL to be applied, got %v", r.Applied)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Expected the file change to trigger a reload")
+	}
+	if live.Current().LogLevel != "warn" {
+		t.Errorf("Expected log level warn, got %q", live.Current().LogLevel)
+	}
+}
+
+func mustFields(t *testing.T, cfg *Config) []field {
+	t.Helper()
+	fs, err := fields(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return fs
+}
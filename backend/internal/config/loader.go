@@ -0,0 +1,213 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrRequired is wrapped by a FieldError for a required variable that is not set
+var ErrRequired = errors.New("required variable is not set")
+
+// FieldError reports a setting that could not be loaded
+type FieldError struct {
+	// Key is the environment variable name, including any prefix
+	Key   string
+	Value string
+	Err   error
+}
+
+func (e *FieldError) Error() string {
+	if errors.Is(e.Err, ErrRequired) {
+		return fmt.Sprintf("%s: %v", e.Key, e.Err)
+	}
+	return fmt.Sprintf("%s=%q: %v", e.Key, e.Value, e.Err)
+}
+
+func (e *FieldError) Unwrap() error {
+	return e.Err
+}
+
+// field is a single setting discovered from struct tags:
+//
+//	env:"PORT"         environment variable name (required to be a setting)
+//	default:"8080"     value used when no layer sets it
+//	required:"true"    fail when no layer sets it
+//	secret:"true"      redact when printing
+//	reload:"live"      may change on Live.Reload without a restart
+//	usage:"HTTP port"  flag help text
+//	envPrefix:"DB_"    on a nested struct, prefix for all of its variables
+type field struct {
+	key        string
+	def        string
+	hasDefault bool
+	required   bool
+	secret     bool
+	live       bool
+	usage      string
+	value      reflect.Value
+}
+
+// fields walks a pointer to a struct and returns its settings in
+// declaration order, descending into nested structs
+func fields(target any) ([]field, error) {
+	v := reflect.ValueOf(target)
+	if v.Kind() != reflect.Pointer || v.Elem().Kind() != reflect.Struct {
+		return nil, fmt.Errorf("config: target must be a pointer to a struct, got %T", target)
+	}
+
+	var out []field
+	if err := collect(v.Elem(), "", &out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func collect(v reflect.Value, prefix string, out *[]field) error {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if !sf.IsExported() {
+			continue
+		}
+
+		fv := v.Field(i)
+		key, hasKey := sf.Tag.Lookup("env")
+		if !hasKey {
+			if sf.Type.Kind() == reflect.Struct {
+				if err := collect(fv, prefix+sf.Tag.Get("envPrefix"), out); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+
+		if !supported(sf.Type) {
+			return fmt.Errorf("config: field %s has unsupported type %s", sf.Name, sf.Type)
+		}
+		def, hasDefault := sf.Tag.Lookup("default")
+		*out = append(*out, field{
+			key:        prefix + key,
+			def:        def,
+			hasDefault: hasDefault,
+			required:   sf.Tag.Get("required") == "true",
+			secret:     sf.Tag.Get("secret") == "true",
+			live:       sf.Tag.Get("reload") == "live",
+			usage:      sf.Tag.Get("usage"),
+			value:      fv,
+		})
+	}
+	return nil
+}
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+func supported(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.String, reflect.Bool,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	case reflect.Slice:
+		return t.Elem().Kind() == reflect.String
+	}
+	return false
+}
+
+// set parses raw into the field, returning a FieldError on failure
+func (f field) set(raw string) error {
+	v := f.value
+	fail := func(err error) error {
+		return &FieldError{Key: f.key, Value: raw, Err: err}
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(raw)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return fail(errors.New("not a boolean"))
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if v.Type() == durationType {
+			d, err := time.ParseDuration(raw)
+			if err != nil {
+				return fail(errors.New("not a duration"))
+			}
+			v.SetInt(int64(d))
+			return nil
+		}
+		n, err := strconv.ParseInt(raw, 10, v.Type().Bits())
+		if err != nil {
+			return fail(errors.New("not an integer"))
+		}
+		v.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(raw, 10, v.Type().Bits())
+		if err != nil {
+			return fail(errors.New("not an unsigned integer"))
+		}
+		v.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		n, err := strconv.ParseFloat(raw, v.Type().Bits())
+		if err != nil {
+			return fail(errors.New("not a number"))
+		}
+		v.SetFloat(n)
+	case reflect.Slice:
+		v.Set(reflect.ValueOf(splitList(raw)))
+	}
+	return nil
+}
+
+// String formats the current value the way it would be written in the
+// environment
+func (f field) String() string {
+	v := f.value
+	switch v.Kind() {
+	case reflect.Slice:
+		return strings.Join(v.Interface().([]string), ",")
+	case reflect.Int64:
+		if v.Type() == durationType {
+			return time.Duration(v.Int()).String()
+		}
+	}
+	return fmt.Sprint(v.Interface())
+}
+
+func (f field) isBool() bool {
+	return f.value.Kind() == reflect.Bool
+}
+
+// splitList parses a comma-separated list, dropping empty entries
+func splitList(raw string) []string {
+	items := []string{}
+	for _, item := range strings.Split(raw, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// Process fills target, a pointer to a struct, from its default tags and
+// then from environment variables. All problems are returned together,
+// each one a *FieldError, joined with errors.Join.
+func Process(target any) error {
+	fields, err := fields(target)
+	if err != nil {
+		return err
+	}
+
+	sources := make(map[string]Source)
+	errs := applyDefaults(fields, sources)
+	errs = append(errs, applyEnv(fields, sources)...)
+	errs = append(errs, checkRequired(fields, sources)...)
+	return errors.Join(errs...)
+}
@@ -0,0 +1,106 @@
+package config
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+type testDatabase struct {
+	URL      string        `env:"URL" required:"true"`
+	MaxConns int           `env:"MAX_CONNS" default:"10"`
+	Timeout  time.Duration `env:"TIMEOUT" default:"5s"`
+}
+
+type testConfig struct {
+	Name     string       `env:"NAME" default:"app"`
+	Debug    bool         `env:"DEBUG"`
+	Ratio    float64      `env:"RATIO" default:"0.5"`
+	Tags     []string     `env:"TAGS" default:"a,b"`
+	Database testDatabase `envPrefix:"DB_"`
+
+	ignored string
+}
+
+func TestProcess(t *testing.T) {
+	t.Setenv("DEBUG", "true")
+	t.Setenv("TAGS", "x, y ,z")
+	t.Setenv("DB_URL", "postgres://db/app")
+	t.Setenv("DB_TIMEOUT", "1m30s")
+
+	var cfg testConfig
+	if err := Process(&cfg); err != nil {
+		t.Fatalf("Process: %v", err)
+	}
+
+	if cfg.Name != "app" || !cfg.Debug || cfg.Ratio != 0.5 {
+		t.Errorf("Unexpected scalar values: %+v", cfg)
+	}
+	if len(cfg.Tags) != 3 || cfg.Tags[1] != "y" {
+		t.Errorf("Expected tags [x y z], got %q", cfg.Tags)
+	}
+	if cfg.Database.URL != "postgres://db/app" || cfg.Database.MaxConns != 10 {
+		t.Errorf("Unexpected nested values: %+v", cfg.Database)
+	}
+	if cfg.Database.Timeout != 90*time.Second {
+		t.Errorf("Expected timeout 1m30s, got %v", cfg.Database.Timeout)
+	}
+}
+
+func TestProcessErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		env     map[string]string
+		wantKey string
+		wantErr error
+	}{
+		{"missing required", map[string]string{}, "DB_URL", ErrRequired},
+		{"bad int", map[string]string{"DB_URL": "x", "DB_MAX_CONNS": "ten"}, "DB_MAX_CONNS", nil},
+		{"bad bool", map[string]string{"DB_URL": "x", "DEBUG": "yes please"}, "DEBUG", nil},
+		{"bad duration", map[string]string{"DB_URL": "x", "DB_TIMEOUT": "5"}, "DB_TIMEOUT", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+
+			var cfg testConfig
+			err := Process(&cfg)
+
+			var fieldErr *FieldError
+			if !errors.As(err, &fieldErr) {
+				t.Fatalf("Expected a FieldError, got %v", err)
+			}
+			if fieldErr.Key != tt.wantKey {
+				t.Errorf("Expected error for %s, got %s", tt.wantKey, fieldErr.Key)
+			}
+			if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Errorf("Expected %v, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestProcessRejectsUnsupportedTypes(t *testing.T) {
+	var target struct {
+		Ports []int `env:"PORTS"`
+	}
+	if err := Process(&target); err == nil {
+		t.Error("Expected an error for an unsupported field type")
+	}
+	if err := Process(target); err == nil {
+		t.Error("Expected an error for a non-pointer target")
+	}
+}
+
+func TestConfigDefaultsMatchConstants(t *testing.T) {
+	cfg, _ := newConfig()
+	if defaultDatabaseURL == "" || defaultJWTSecret == "" {
+		t.Fatal("Expected defaultDatabaseURL and defaultJWTSecret to be read from the default tags")
+	}
+	if cfg.DatabaseURL != defaultDatabaseURL || cfg.JWTSecret != defaultJWTSecret {
+		t.Error("Expected the default tags to match defaultDatabaseURL and defaultJWTSecret")
+	}
+}
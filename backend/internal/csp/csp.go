@@ -0,0 +1,107 @@
+// Package csp builds Content-Security-Policy headers
+package csp
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"strings"
+)
+
+// Directive is a CSP directive name
+type Directive string
+
+const (
+	DefaultSrc     Directive = "default-src"
+	ScriptSrc      Directive = "script-src"
+	StyleSrc       Directive = "style-src"
+	ImgSrc         Directive = "img-src"
+	FontSrc        Directive = "font-src"
+	ConnectSrc     Directive = "connect-src"
+	MediaSrc       Directive = "media-src"
+	ObjectSrc      Directive = "object-src"
+	FrameSrc       Directive = "frame-src"
+	WorkerSrc      Directive = "worker-src"
+	ManifestSrc    Directive = "manifest-src"
+	FrameAncestors Directive = "frame-ancestors"
+	BaseURI        Directive = "base-uri"
+	FormAction     Directive = "form-action"
+	ReportURI      Directive = "report-uri"
+	ReportTo       Directive = "report-to"
+	// UpgradeInsecureRequests takes no sources
+	UpgradeInsecureRequests Directive = "upgrade-insecure-requests"
+)
+
+// Source is a CSP source expression. Hosts are written as is, e.g.
+// csp.Source("https://cdn.example.com").
+type Source string
+
+const (
+	Self          Source = "'self'"
+	None          Source = "'none'"
+	UnsafeInline  Source = "'unsafe-inline'"
+	UnsafeEval    Source = "'unsafe-eval'"
+	StrictDynamic Source = "'strict-dynamic'"
+	Data          Source = "data:"
+	Blob          Source = "blob:"
+	HTTPS         Source = "https:"
+	// Nonce is replaced by 'nonce-<value>' with the nonce of each request
+	Nonce Source = "'nonce'"
+)
+
+// Policy is an ordered set of directives. Build it once at start-up and
+// render it per request with Header.
+type Policy struct {
+	directives []Directive
+	sources    map[Directive][]Source
+}
+
+// New returns an empty policy
+func New() *Policy {
+	return &Policy{sources: make(map[Directive][]Source)}
+}
+
+// Set adds sources to directive, keeping the order directives were first
+// set in
+func (p *Policy) Set(directive Directive, sources ...Source) *Policy {
+	if _, ok := p.sources[directive]; !ok {
+		p.directives = append(p.directives, directive)
+		p.sources[directive] = nil
+	}
+	p.sources[directive] = append(p.sources[directive], sources...)
+	return p
+}
+
+// UsesNonce reports whether any directive contains Nonce
+func (p *Policy) UsesNonce() bool {
+	for _, sources := range p.sources {
+		for _, s := range sources {
+			if s == Nonce {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Header renders the policy with nonce substituted for Nonce
+func (p *Policy) Header(nonce string) string {
+	parts := make([]string, 0, len(p.directives))
+	for _, d := range p.directives {
+		part := string(d)
+		for _, s := range p.sources[d] {
+			if s == Nonce {
+				s = Source("'nonce-" + nonce + "'")
+			}
+			part += " " + string(s)
+		}
+		parts = append(parts, part)
+	}
+	return strings.Join(parts, "; ")
+}
+
+// NewNonce returns a random base64 nonce with 128 bits of entropy
+func NewNonce() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return base64.StdEncoding.EncodeToString(b)
+}
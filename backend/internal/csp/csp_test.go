@@ -0,0 +1,40 @@
+package csp
+
+import (
+	"encoding/base64"
+	"testing"
+)
+
+func TestPolicyHeader(t *testing.T) {
+	policy := New().
+		Set(DefaultSrc, Self).
+		Set(ScriptSrc, Self, Nonce, StrictDynamic).
+		Set(ImgSrc, Self, Data, Source("https://cdn.example.com")).
+		Set(DefaultSrc, HTTPS).
+		Set(UpgradeInsecureRequests)
+
+	want := "default-src 'self' https:; " +
+		"script-src 'self' 'nonce-abc' 'strict-dynamic'; " +
+		"img-src 'self' data: https://cdn.example.com; " +
+		"upgrade-insecure-requests"
+	if got := policy.Header("abc"); got != want {
+		t.Errorf("Expected %q, got %q", want, got)
+	}
+	if !policy.UsesNonce() {
+		t.Error("Expected the policy to use a nonce")
+	}
+	if New().Set(DefaultSrc, None).UsesNonce() {
+		t.Error("Expected a policy without Nonce not to use one")
+	}
+}
+
+func TestNewNonce(t *testing.T) {
+	a, b := NewNonce(), NewNonce()
+	if a == b {
+		t.Error("Expected nonces to differ")
+	}
+	raw, err := base64.StdEncoding.DecodeString(a)
+	if err != nil || len(raw) != 16 {
+		t.Errorf("Expected 16 random bytes in base64, got %q", a)
+	}
+}
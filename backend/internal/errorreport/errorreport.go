@@ -0,0 +1,81 @@
+// Package errorreport delivers unexpected server errors, such as recovered
+// panics, to wherever they are tracked
+package errorreport
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Event describes a single error with the request it happened in.
+// Credentials are scrubbed before an Event is built.
+type Event struct {
+	Time      time.Time   `json:"time"`
+	Message   string      `json:"message"`
+	Stack     string      `json:"stack,omitempty"`
+	RequestID string      `json:"request_id,omitempty"`
+	Method    string      `json:"method,omitempty"`
+	Route     string      `json:"route,omitempty"`
+	Path      string      `json:"path,omitempty"`
+	Query     string      `json:"query,omitempty"`
+	ClientIP  string      `json:"client_ip,omitempty"`
+	UserID    string      `json:"user_id,omitempty"`
+	Headers   http.Header `json:"headers,omitempty"`
+}
+
+// ErrorReporter receives error events. Report must be safe for concurrent
+// use and should not block the request for long.
+type ErrorReporter interface {
+	Report(ctx context.Context, event Event)
+}
+
+// JSONReporter writes each event as one line of JSON
+type JSONReporter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// NewJSONReporter returns a reporter writing to w, usually os.Stderr
+func NewJSONReporter(w io.Writer) *JSONReporter {
+	return &JSONReporter{w: w}
+}
+
+// Report implements ErrorReporter
+func (r *JSONReporter) Report(_ context.Context, event Event) {
+	line, err := json.Marshal(event)
+	if err != nil {
+		log.Printf("❌ Failed to encode error event: %v", err)
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, err := r.w.Write(append(line, '\n')); err != nil {
+		log.Printf("❌ Failed to write error event: %v", err)
+	}
+}
+
+// MemoryReporter keeps events in memory so tests can inspect them
+type MemoryReporter struct {
+	mu     sync.Mutex
+	events []Event
+}
+
+// Report implements ErrorReporter
+func (r *MemoryReporter) Report(_ context.Context, event Event) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, event)
+}
+
+// Events returns a copy of the events reported so far
+func (r *MemoryReporter) Events() []Event {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]Event(nil), r.events...)
+}
@@ -0,0 +1,50 @@
+package errorreport
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestJSONReporterWritesOneLinePerEvent(t *testing.T) {
+	var buf bytes.Buffer
+	reporter := NewJSONReporter(&buf)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			reporter.Report(context.Background(), Event{Message: "panic: boom", Stack: "line 1\nline 2"})
+		}()
+	}
+	wg.Wait()
+
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	if len(lines) != 10 {
+		t.Fatalf("Expected 10 lines, got %d", len(lines))
+	}
+	for _, line := range lines {
+		var event Event
+		if err := json.Unmarshal([]byte(line), &event); err != nil {
+			t.Fatalf("Expected a JSON event per line, got %q", line)
+		}
+		if event.Message != "panic: boom" || event.Stack != "line 1\nline 2" {
+			t.Errorf("Unexpected event: %+v", event)
+		}
+	}
+}
+
+func TestMemoryReporter(t *testing.T) {
+	reporter := &MemoryReporter{}
+	reporter.Report(context.Background(), Event{Message: "first"})
+	reporter.Report(context.Background(), Event{Message: "second"})
+
+	events := reporter.Events()
+	if len(events) != 2 || events[0].Message != "first" || events[1].Message != "second" {
+		t.Errorf("Expected both events in order, got %+v", events)
+	}
+}
@@ -0,0 +1,183 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/apierror"
+	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/auth"
+	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/logging"
+)
+
+// Auth serves registration, login and the token endpoints
+type Auth struct {
+	service *auth.Service
+}
+
+// NewAuth returns the auth handlers for service
+func NewAuth(service *auth.Service) *Auth {
+	return &Auth{service: service}
+}
+
+type registerRequest struct {
+	Email    string `json:"email" binding:"required"`
+	Password string `json:"password" binding:"required"`
+	Name     string `json:"name"`
+}
+
+type loginRequest struct {
+	Email    string `json:"email" binding:"required"`
+	Password string `json:"password" binding:"required"`
+}
+
+type refreshRequest struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
+}
+
+type userResponse struct {
+	ID        string    `json:"id"`
+	Email     string    `json:"email"`
+	Name      string    `json:"name"`
+	Roles     []string  `json:"roles"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type tokenResponse struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int    `json:"expires_in"`
+}
+
+func newTokenResponse(tokens auth.Tokens) tokenResponse {
+	return tokenResponse{
+		AccessToken:  tokens.AccessToken,
+		RefreshToken: tokens.RefreshToken,
+		TokenType:    "Bearer",
+		ExpiresIn:    int(tokens.ExpiresIn / time.Second),
+	}
+}
+
+// Register handles POST /users/register: it creates the account and
+// returns it with a first pair of tokens
+func (h *Auth) Register(c *gin.Context) {
+	var req registerRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	user, tokens, err := h.service.Register(c.Request.Context(), req.Email, req.Password, req.Name)
+	switch {
+	case errors.Is(err, auth.ErrInvalidEmail):
+		apierror.Abort(c, http.StatusBadRequest, "invalid_email", err.Error())
+		return
+	case errors.Is(err, auth.ErrInvalidPassword):
+		apierror.Abort(c, http.StatusBadRequest, "invalid_password", err.Error())
+		return
+	case errors.Is(err, auth.ErrEmailTaken):
+		apierror.Abort(c, http.StatusConflict, "email_taken", "an account with this email already exists")
+		return
+	case err != nil:
+		internalError(c, "register", err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"user": userResponse{
+			ID:        user.ID,
+			Email:     user.Email,
+			Name:      user.Name,
+			Roles:     user.Roles,
+			CreatedAt: user.CreatedAt,
+		},
+		"tokens": newTokenResponse(tokens),
+	})
+}
+
+// Login handles POST /users/login
+func (h *Auth) Login(c *gin.Context) {
+	var req loginRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	tokens, err := h.service.Login(c.Request.Context(), req.Email, req.Password)
+	var throttled *auth.ThrottledError
+	switch {
+	case errors.As(err, &throttled):
+		c.Header("Retry-After", strconv.Itoa(int((throttled.RetryAfter+time.Second-1)/time.Second)))
+		apierror.Abort(c, http.StatusTooManyRequests, "login_throttled", "too many login attempts, retry later")
+		return
+	case errors.Is(err, auth.ErrInvalidCredentials):
+		apierror.Abort(c, http.StatusUnauthorized, "invalid_credentials", err.Error())
+		return
+	case err != nil:
+		internalError(c, "login", err)
+		return
+	}
+	c.JSON(http.StatusOK, newTokenResponse(tokens))
+}
+
+// Refresh handles POST /users/refresh, exchanging a refresh token for a
+// new pair. A refresh token presented twice revokes its session.
+func (h *Auth) Refresh(c *gin.Context) {
+	var req refreshRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	tokens, err := h.service.Refresh(c.Request.Context(), req.RefreshToken)
+	switch {
+	case errors.Is(err, auth.ErrTokenUsed):
+		logging.FromContext(c.Request.Context()).Warn("refresh token reused, session revoked")
+		apierror.Abort(c, http.StatusUnauthorized, "refresh_token_reused", "refresh token was already used; sign in again")
+		return
+	case errors.Is(err, auth.ErrTokenNotFound):
+		apierror.Abort(c, http.StatusUnauthorized, "invalid_refresh_token", "refresh token is invalid or expired")
+		return
+	case err != nil:
+		internalError(c, "refresh", err)
+		return
+	}
+	c.JSON(http.StatusOK, newTokenResponse(tokens))
+}
+
+// Logout handles POST /users/logout, revoking the session of the refresh
+// token
+func (h *Auth) Logout(c *gin.Context) {
+	var req refreshRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	if err := h.service.Logout(c.Request.Context(), req.RefreshToken); err != nil {
+		internalError(c, "logout", err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// bindJSON decodes the request body into v, answering 400 if it is
+// malformed. Bodies cut off by BodyLimit are left to it to answer with 413.
+func bindJSON(c *gin.Context, v any) bool {
+	err := c.ShouldBindJSON(v)
+	if err == nil {
+		return true
+	}
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		c.Abort()
+		return false
+	}
+	apierror.Abort(c, http.StatusBadRequest, "invalid_body", "request body is not valid: "+err.Error())
+	return false
+}
+
+// internalError logs err and answers 500 without exposing it
+func internalError(c *gin.Context, action string, err error) {
+	logging.FromContext(c.Request.Context()).Error(action+" failed", "error", err)
+	apierror.Abort(c, http.StatusInternalServerError, "internal_error", "an unexpected error occurred")
+}
@@ -0,0 +1,140 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/auth"
+	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/middleware"
+	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/ratelimit"
+	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/storage"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func newAuthRouter() *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	jwtAuth := middleware.NewJWTAuth(middleware.JWTConfig{Secret: strings.Repeat("s", 32)})
+	service := auth.NewService(storage.NewMemory().Users(), auth.NewMemoryTokens(), jwtAuth, ratelimit.NewMemoryStore(), auth.Config{
+		AccessTTL:  time.Minute,
+		RefreshTTL: time.Hour,
+		BcryptCost: bcrypt.MinCost,
+		LoginLimit: ratelimit.Limit{Rate: 1.0 / 60, Burst: 2},
+	})
+	h := NewAuth(service)
+
+	router := gin.New()
+	users := router.Group("/users", middleware.BodyLimit(256))
+	users.POST("/register", h.Register)
+	users.POST("/login", h.Login)
+	users.POST("/refresh", h.Refresh)
+	users.POST("/logout", h.Logout)
+	users.GET("/me", jwtAuth.Handler(), Me)
+	return router
+}
+
+// postJSON sends body to path and decodes the response into a map
+func postJSON(router *gin.Engine, path, body string) (*httptest.ResponseRecorder, map[string]any) {
+	req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	var decoded map[string]any
+	json.Unmarshal(w.Body.Bytes(), &decoded)
+	return w, decoded
+}
+
+func errorCode(body map[string]any) string {
+	if e, ok := body["error"].(map[string]any); ok {
+		code, _ := e["code"].(string)
+		return code
+	}
+	return ""
+}
+
+func TestAuthFlow(t *testing.T) {
+	router := newAuthRouter()
+
+	w, body := postJSON(router, "/users/register", `{"email":"alice@example.com","password":"correct horse","name":"Alice"}`)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Expected 201 from register, got %d: %s", w.Code, w.Body)
+	}
+	user := body["user"].(map[string]any)
+	if user["email"] != "alice@example.com" || user["password_hash"] != nil {
+		t.Errorf("Expected the user without the password hash, got %v", user)
+	}
+
+	w, tokens := postJSON(router, "/users/login", `{"email":"alice@example.com","password":"correct horse"}`)
+	if w.Code != http.StatusOK || tokens["token_type"] != "Bearer" || tokens["expires_in"] != float64(60) {
+		t.Fatalf("Expected 200 with bearer tokens from login, got %d: %s", w.Code, w.Body)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/users/me", nil)
+	req.Header.Set("Authorization", "Bearer "+tokens["access_token"].(string))
+	me := httptest.NewRecorder()
+	router.ServeHTTP(me, req)
+	if me.Code != http.StatusOK || !strings.Contains(me.Body.String(), user["id"].(string)) {
+		t.Errorf("Expected the access token to authenticate the user, got %d: %s", me.Code, me.Body)
+	}
+
+	refresh := `{"refresh_token":"` + tokens["refresh_token"].(string) + `"}`
+	if w, _ := postJSON(router, "/users/refresh", refresh); w.Code != http.StatusOK {
+		t.Errorf("Expected 200 from refresh, got %d: %s", w.Code, w.Body)
+	}
+	if w, body := postJSON(router, "/users/refresh", refresh); w.Code != http.StatusUnauthorized || errorCode(body) != "refresh_token_reused" {
+		t.Errorf("Expected 401 refresh_token_reused, got %d: %s", w.Code, w.Body)
+	}
+	if w, _ := postJSON(router, "/users/logout", refresh); w.Code != http.StatusNoContent {
+		t.Errorf("Expected 204 from logout, got %d", w.Code)
+	}
+}
+
+func TestAuthErrors(t *testing.T) {
+	router := newAuthRouter()
+	postJSON(router, "/users/register", `{"email":"alice@example.com","password":"correct horse"}`)
+
+	tests := []struct {
+		name       string
+		path       string
+		body       string
+		wantStatus int
+		wantCode   string
+	}{
+		{"email taken", "/users/register", `{"email":"alice@example.com","password":"another one"}`, http.StatusConflict, "email_taken"},
+		{"invalid email", "/users/register", `{"email":"alice","password":"correct horse"}`, http.StatusBadRequest, "invalid_email"},
+		{"short password", "/users/register", `{"email":"bob@example.com","password":"short"}`, http.StatusBadRequest, "invalid_password"},
+		{"missing field", "/users/register", `{"email":"bob@example.com"}`, http.StatusBadRequest, "invalid_body"},
+		{"malformed JSON", "/users/login", `{"email":`, http.StatusBadRequest, "invalid_body"},
+		{"body too large", "/users/register", `{"email":"bob@example.com","password":"` + strings.Repeat("p", 300) + `"}`, http.StatusRequestEntityTooLarge, "request_too_large"},
+		{"wrong password", "/users/login", `{"email":"alice@example.com","password":"wrong horse"}`, http.StatusUnauthorized, "invalid_credentials"},
+		{"unknown refresh token", "/users/refresh", `{"refresh_token":"made-up"}`, http.StatusUnauthorized, "invalid_refresh_token"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w, body := postJSON(router, tt.path, tt.body)
+			if w.Code != tt.wantStatus || errorCode(body) != tt.wantCode {
+				t.Errorf("Expected %d %s, got %d: %s", tt.wantStatus, tt.wantCode, w.Code, w.Body)
+			}
+		})
+	}
+}
+
+func TestLoginThrottled(t *testing.T) {
+	router := newAuthRouter()
+	postJSON(router, "/users/register", `{"email":"alice@example.com","password":"correct horse"}`)
+
+	for i := 0; i < 2; i++ {
+		postJSON(router, "/users/login", `{"email":"alice@example.com","password":"guess"}`)
+	}
+	w, body := postJSON(router, "/users/login", `{"email":"alice@example.com","password":"correct horse"}`)
+	if w.Code != http.StatusTooManyRequests || errorCode(body) != "login_throttled" {
+		t.Fatalf("Expected 429 login_throttled, got %d: %s", w.Code, w.Body)
+	}
+	if retry := w.Header().Get("Retry-After"); retry == "" || retry == "0" {
+		t.Errorf("Expected a Retry-After header, got %q", retry)
+	}
+}
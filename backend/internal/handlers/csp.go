@@ -0,0 +1,98 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/apierror"
+	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/logging"
+)
+
+// cspViolation is a violation in the legacy report-uri format
+type cspViolation struct {
+	DocumentURI        string `json:"document-uri"`
+	BlockedURI         string `json:"blocked-uri"`
+	ViolatedDirective  string `json:"violated-directive"`
+	EffectiveDirective string `json:"effective-directive"`
+	Disposition        string `json:"disposition"`
+	SourceFile         string `json:"source-file"`
+	LineNumber         int    `json:"line-number"`
+}
+
+// cspReportBody is a violation as sent by the Reporting API
+type cspReportBody struct {
+	DocumentURL        string `json:"documentURL"`
+	BlockedURL         string `json:"blockedURL"`
+	EffectiveDirective string `json:"effectiveDirective"`
+	Disposition        string `json:"disposition"`
+	SourceFile         string `json:"sourceFile"`
+	LineNumber         int    `json:"lineNumber"`
+}
+
+// CSPReport receives Content-Security-Policy violation reports, sent either
+// as application/csp-report to report-uri or as application/reports+json
+// by the Reporting API, and logs each violation
+func CSPReport(c *gin.Context) {
+	body, err := io.ReadAll(c.Request.Body)
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		// BodyLimit answers 413
+		c.Abort()
+		return
+	}
+	if err != nil {
+		apierror.Abort(c, http.StatusBadRequest, "invalid_report", "could not read the report")
+		return
+	}
+
+	var violations []cspViolation
+	var legacy struct {
+		Report *cspViolation `json:"csp-report"`
+	}
+	var reports []struct {
+		Type string        `json:"type"`
+		Body cspReportBody `json:"body"`
+	}
+	switch {
+	case json.Unmarshal(body, &legacy) == nil && legacy.Report != nil:
+		violations = append(violations, *legacy.Report)
+	case json.Unmarshal(body, &reports) == nil:
+		for _, r := range reports {
+			if r.Type == "csp-violation" {
+				violations = append(violations, cspViolation{
+					DocumentURI:        r.Body.DocumentURL,
+					BlockedURI:         r.Body.BlockedURL,
+					EffectiveDirective: r.Body.EffectiveDirective,
+					Disposition:        r.Body.Disposition,
+					SourceFile:         r.Body.SourceFile,
+					LineNumber:         r.Body.LineNumber,
+				})
+			}
+		}
+	default:
+		apierror.Abort(c, http.StatusBadRequest, "invalid_report", "the report is not valid JSON")
+		return
+	}
+
+	logger := logging.FromContext(c.Request.Context())
+	for _, v := range violations {
+		directive := v.EffectiveDirective
+		if directive == "" {
+			directive = v.ViolatedDirective
+		}
+		logger.LogAttrs(c.Request.Context(), slog.LevelWarn, "csp violation",
+			slog.String("document_uri", v.DocumentURI),
+			slog.String("blocked_uri", v.BlockedURI),
+			slog.String("directive", directive),
+			slog.String("disposition", v.Disposition),
+			slog.String("source_file", v.SourceFile),
+			slog.Int("line", v.LineNumber),
+			slog.String("user_agent", c.Request.UserAgent()),
+		)
+	}
+	c.Status(http.StatusNoContent)
+}
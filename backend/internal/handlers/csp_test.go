@@ -0,0 +1,96 @@
+package handlers
+
+import (
+	"bytes"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/logging"
+	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/middleware"
+)
+
+func TestCSPReport(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name       string
+		body       string
+		wantStatus int
+		wantCount  int
+		wantLogged []string
+	}{
+		{
+			name:       "report-uri format",
+			body:       `{"csp-report":{"document-uri":"https://app.example.com/","blocked-uri":"https://evil.io/x.js","violated-directive":"script-src-elem","line-number":3}}`,
+			wantStatus: http.StatusNoContent,
+			wantCount:  1,
+			wantLogged: []string{`"blocked_uri":"https://evil.io/x.js"`, `"directive":"script-src-elem"`, `"line":3`},
+		},
+		{
+			name:       "Reporting API format",
+			body:       `[{"type":"csp-violation","body":{"documentURL":"https://app.example.com/","blockedURL":"inline","effectiveDirective":"style-src","disposition":"report"}},{"type":"deprecation","body":{}}]`,
+			wantStatus: http.StatusNoContent,
+			wantCount:  1,
+			wantLogged: []string{`"blocked_uri":"inline"`, `"directive":"style-src"`, `"disposition":"report"`},
+		},
+		{
+			name:       "not JSON",
+			body:       `blocked`,
+			wantStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			logger := slog.New(slog.NewJSONHandler(&buf, nil))
+
+			router := gin.New()
+			router.POST("/csp-report", func(c *gin.Context) {
+				c.Request = c.Request.WithContext(logging.WithLogger(c.Request.Context(), logger))
+			}, CSPReport)
+
+			req := httptest.NewRequest(http.MethodPost, "/csp-report", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/csp-report")
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("Expected status %d, got %d", tt.wantStatus, w.Code)
+			}
+			if got := strings.Count(buf.String(), "csp violation"); got != tt.wantCount {
+				t.Errorf("Expected %d logged violations, got %d: %s", tt.wantCount, got, buf.String())
+			}
+			for _, want := range tt.wantLogged {
+				if !strings.Contains(buf.String(), want) {
+					t.Errorf("Expected %s in the log, got %s", want, buf.String())
+				}
+			}
+		})
+	}
+}
+
+func TestCSPReportTooLarge(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/csp-report", middleware.BodyLimit(64), CSPReport)
+
+	body := `{"csp-report":{"blocked-uri":"https://evil.io/` + strings.Repeat("x", 100) + `"}}`
+	for _, declared := range []bool{true, false} {
+		req := httptest.NewRequest(http.MethodPost, "/csp-report", strings.NewReader(body))
+		if !declared {
+			req.ContentLength = -1
+		}
+		req.Header.Set("Content-Type", "application/csp-report")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		if w.Code != http.StatusRequestEntityTooLarge || !strings.Contains(w.Body.String(), "request_too_large") {
+			t.Errorf("Declared length %v: expected 413 request_too_large, got %d %s", declared, w.Code, w.Body.String())
+		}
+	}
+}
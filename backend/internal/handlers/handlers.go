@@ -1,23 +1,63 @@
 package handlers
 
 import (
+	"maps"
 	"net/http"
+	"slices"
 
 	"github.com/gin-gonic/gin"
+	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/buildinfo"
+	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/health"
+	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/logging"
+	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/middleware"
 )
 
-// HealthCheck returns server health status
+// HealthCheck reports that the process is up, along with its build. It
+// doesn't check dependencies, so it serves as the liveness probe.
 func HealthCheck(c *gin.Context) {
+	build := buildinfo.Get()
 	c.JSON(http.StatusOK, gin.H{
-		"status":  "healthy",
-		"service": "sum25-go-flutter-course-backend",
-		"version": "1.0.0",
+		"status":     "healthy",
+		"service":    "sum25-go-flutter-course-backend",
+		"version":    build.Version,
+		"commit":     build.Commit,
+		"build_time": build.BuildTime,
 	})
 }
 
+// Ready runs the dependency checks of h for the readiness probe: 200 when
+// they all pass, 503 with each check's status otherwise, and 503 once
+// shutdown has started. Why a check failed is logged, not returned.
+func Ready(h *health.Health) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		report := h.Check(c.Request.Context())
+		status := http.StatusOK
+		if !report.Ready() {
+			status = http.StatusServiceUnavailable
+		}
+		for _, name := range slices.Sorted(maps.Keys(report.Checks)) {
+			if result := report.Checks[name]; result.Status != health.StatusOK {
+				logging.FromContext(c.Request.Context()).Warn("readiness check failed", "check", name, "error", result.Error)
+			}
+		}
+		c.Header("Cache-Control", "no-store")
+		c.JSON(status, report)
+	}
+}
+
 // Ping returns a simple pong response
 func Ping(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{
 		"message": "pong",
 	})
 }
+
+// Me returns the identity of the authenticated caller
+func Me(c *gin.Context) {
+	claims, _ := middleware.ClaimsFrom(c)
+	c.JSON(http.StatusOK, gin.H{
+		"user_id": claims.UserID(),
+		"roles":   claims.Roles,
+		"scope":   claims.Scope,
+	})
+}
@@ -0,0 +1,94 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/buildinfo"
+	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/health"
+	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/logging"
+)
+
+func TestHealthCheckReportsBuild(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	defer func(v, c string) { buildinfo.Version, buildinfo.Commit = v, c }(buildinfo.Version, buildinfo.Commit)
+	buildinfo.Version, buildinfo.Commit = "v1.4.0", "abc123"
+
+	router := gin.New()
+	router.GET("/health/live", HealthCheck)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/health/live", nil))
+
+	var body map[string]string
+	json.Unmarshal(w.Body.Bytes(), &body)
+	if w.Code != http.StatusOK || body["status"] != "healthy" {
+		t.Errorf("Expected 200 healthy, got %d %s", w.Code, w.Body)
+	}
+	if body["version"] != "v1.4.0" || body["commit"] != "abc123" {
+		t.Errorf("Expected the injected version and commit, got %s", w.Body)
+	}
+}
+
+func TestReady(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	redisUp := true
+	probes := health.New(time.Second)
+	probes.Register("postgres", health.CheckerFunc(func(ctx context.Context) error { return nil }))
+	probes.Register("redis", health.CheckerFunc(func(ctx context.Context) error {
+		if !redisUp {
+			return errors.New("connection refused")
+		}
+		return nil
+	}))
+
+	var logs bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&logs, nil))
+
+	router := gin.New()
+	router.GET("/health/ready", func(c *gin.Context) {
+		c.Request = c.Request.WithContext(logging.WithLogger(c.Request.Context(), logger))
+	}, Ready(probes))
+	var body string
+	probe := func() (int, health.Report) {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/health/ready", nil))
+		body = w.Body.String()
+		var report health.Report
+		json.Unmarshal(w.Body.Bytes(), &report)
+		return w.Code, report
+	}
+
+	if code, report := probe(); code != http.StatusOK || report.Checks["redis"].Status != health.StatusOK {
+		t.Errorf("Expected 200 with passing checks, got %d %+v", code, report)
+	}
+
+	redisUp = false
+	code, report := probe()
+	if code != http.StatusServiceUnavailable || report.Status != health.StatusFail {
+		t.Errorf("Expected 503 when a dependency is down, got %d %+v", code, report)
+	}
+	if report.Checks["postgres"].Status != health.StatusOK || report.Checks["redis"].Status != health.StatusFail {
+		t.Errorf("Expected per-check results, got %+v", report.Checks)
+	}
+	if strings.Contains(body, "connection refused") {
+		t.Errorf("Expected the check's error to stay out of the response, got %s", body)
+	}
+	if !strings.Contains(logs.String(), `"check":"redis","error":"connection refused"`) {
+		t.Errorf("Expected the check's error in the log, got %s", logs.String())
+	}
+
+	redisUp = true
+	probes.ShutDown()
+	if code, report := probe(); code != http.StatusServiceUnavailable || report.Status != health.StatusShuttingDown {
+		t.Errorf("Expected 503 once shutting down, got %d %+v", code, report)
+	}
+}
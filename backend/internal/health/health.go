@@ -0,0 +1,132 @@
+// Package health runs the dependency checks behind the readiness probe
+package health
+
+import (
+	"context"
+	"database/sql"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Statuses reported by checks and probes
+const (
+	StatusOK           = "ok"
+	StatusFail         = "fail"
+	StatusShuttingDown = "shutting_down"
+)
+
+// Checker checks one dependency
+type Checker interface {
+	Check(ctx context.Context) error
+}
+
+// CheckerFunc adapts a function to Checker
+type CheckerFunc func(ctx context.Context) error
+
+// Check implements Checker
+func (f CheckerFunc) Check(ctx context.Context) error {
+	return f(ctx)
+}
+
+// Ping checks that a database answers
+func Ping(db *sql.DB) Checker {
+	return CheckerFunc(db.PingContext)
+}
+
+// Result is the outcome of one check
+type Result struct {
+	Status    string  `json:"status"`
+	LatencyMS float64 `json:"latency_ms"`
+	// Error is left out of the JSON, since driver errors can name hosts
+	// and users; the readiness handler logs it
+	Error string `json:"-"`
+}
+
+// Report is the outcome of a readiness probe
+type Report struct {
+	Status string            `json:"status"`
+	Checks map[string]Result `json:"checks,omitempty"`
+}
+
+// Ready reports whether every check passed and shutdown hasn't started
+func (r Report) Ready() bool {
+	return r.Status == StatusOK
+}
+
+// Health runs the registered checks. Register them before serving.
+type Health struct {
+	timeout      time.Duration
+	names        []string
+	checkers     map[string]Checker
+	shuttingDown atomic.Bool
+}
+
+// New returns a Health that gives each check timeout to finish
+func New(timeout time.Duration) *Health {
+	return &Health{timeout: timeout, checkers: make(map[string]Checker)}
+}
+
+// Register adds a check reported under name
+func (h *Health) Register(name string, checker Checker) {
+	if _, ok := h.checkers[name]; !ok {
+		h.names = append(h.names, name)
+	}
+	h.checkers[name] = checker
+}
+
+// ShutDown makes every later probe fail, so load balancers stop sending
+// traffic before the server closes
+func (h *Health) ShutDown() {
+	h.shuttingDown.Store(true)
+}
+
+// Check runs the checks concurrently and reports each one's status and
+// latency
+func (h *Health) Check(ctx context.Context) Report {
+	if h.shuttingDown.Load() {
+		return Report{Status: StatusShuttingDown}
+	}
+
+	results := make([]Result, len(h.names))
+	var wg sync.WaitGroup
+	for i, name := range h.names {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i] = h.run(ctx, h.checkers[name])
+		}()
+	}
+	wg.Wait()
+
+	report := Report{Status: StatusOK, Checks: make(map[string]Result, len(h.names))}
+	for i, name := range h.names {
+		report.Checks[name] = results[i]
+		if results[i].Status != StatusOK {
+			report.Status = StatusFail
+		}
+	}
+	return report
+}
+
+func (h *Health) run(ctx context.Context, checker Checker) Result {
+	ctx, cancel := context.WithTimeout(ctx, h.timeout)
+	defer cancel()
+
+	// Don't wait past the timeout for checks that ignore ctx
+	start := time.Now()
+	done := make(chan error, 1)
+	go func() { done <- checker.Check(ctx) }()
+	var err error
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+	result := Result{Status: StatusOK, LatencyMS: float64(time.Since(start).Microseconds()) / 1000}
+	if err != nil {
+		result.Status = StatusFail
+		result.Error = err.Error()
+	}
+	return result
+}
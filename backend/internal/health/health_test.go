@@ -0,0 +1,87 @@
+package health
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	_ "modernc.org/sqlite"
+)
+
+func TestCheck(t *testing.T) {
+	h := New(50 * time.Millisecond)
+	h.Register("ok", CheckerFunc(func(ctx context.Context) error { return nil }))
+	h.Register("down", CheckerFunc(func(ctx context.Context) error { return errors.New("connection refused") }))
+	h.Register("slow", CheckerFunc(func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}))
+	h.Register("stuck", CheckerFunc(func(ctx context.Context) error {
+		time.Sleep(time.Second)
+		return nil
+	}))
+
+	start := time.Now()
+	report := h.Check(context.Background())
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("Expected checks to run concurrently within the timeout, took %s", elapsed)
+	}
+
+	if report.Ready() || report.Status != StatusFail {
+		t.Errorf("Expected status %q, got %q", StatusFail, report.Status)
+	}
+	tests := []struct {
+		name       string
+		wantStatus string
+		wantError  string
+	}{
+		{"ok", StatusOK, ""},
+		{"down", StatusFail, "connection refused"},
+		{"slow", StatusFail, "context deadline exceeded"},
+		{"stuck", StatusFail, "context deadline exceeded"},
+	}
+	for _, tt := range tests {
+		result := report.Checks[tt.name]
+		if result.Status != tt.wantStatus || result.Error != tt.wantError {
+			t.Errorf("Expected %s to be %s %q, got %s %q", tt.name, tt.wantStatus, tt.wantError, result.Status, result.Error)
+		}
+	}
+	if report.Checks["slow"].LatencyMS < 50 {
+		t.Errorf("Expected the slow check's latency to be reported, got %vms", report.Checks["slow"].LatencyMS)
+	}
+}
+
+func TestCheckShutDown(t *testing.T) {
+	calls := 0
+	h := New(time.Second)
+	h.Register("db", CheckerFunc(func(ctx context.Context) error { calls++; return nil }))
+
+	if report := h.Check(context.Background()); !report.Ready() {
+		t.Fatalf("Expected passing checks to be ready, got %+v", report)
+	}
+
+	h.ShutDown()
+	report := h.Check(context.Background())
+	if report.Ready() || report.Status != StatusShuttingDown {
+		t.Errorf("Expected status %q after shutdown, got %q", StatusShuttingDown, report.Status)
+	}
+	if calls != 1 {
+		t.Errorf("Expected no checks to run once shutting down, ran %d", calls)
+	}
+}
+
+func TestPing(t *testing.T) {
+	db, err := sql.Open("sqlite", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := Ping(db).Check(context.Background()); err != nil {
+		t.Errorf("Expected an open database to answer, got %v", err)
+	}
+	db.Close()
+	if err := Ping(db).Check(context.Background()); err == nil {
+		t.Error("Expected a closed database to fail")
+	}
+}
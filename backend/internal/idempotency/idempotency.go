@@ -0,0 +1,39 @@
+// Package idempotency stores the responses to requests sent with an
+// Idempotency-Key so retries can be answered without running them again
+package idempotency
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+// Response is a stored response
+type Response struct {
+	Status int
+	Header http.Header
+	Body   []byte
+}
+
+// Record is the state of one key. Response is nil while the first request
+// with the key is still being handled.
+type Record struct {
+	// Fingerprint identifies the request the key was first used with
+	Fingerprint string
+	Response    *Response
+}
+
+// Store keeps records by key. Implementations must make Begin atomic so
+// only one of several concurrent requests with a key is handled.
+type Store interface {
+	// Begin claims key for a request with fingerprint. If the key is new it
+	// is held until Complete or Release and true is returned; otherwise the
+	// existing record is returned with false.
+	Begin(ctx context.Context, key, fingerprint string, ttl time.Duration, now time.Time) (Record, bool, error)
+	// Complete stores the response for a key claimed with Begin; it is
+	// kept until ttl has passed
+	Complete(ctx context.Context, key string, response Response, ttl time.Duration, now time.Time) error
+	// Release drops a claimed key without a response, so the request can be
+	// retried
+	Release(ctx context.Context, key string) error
+}
@@ -0,0 +1,83 @@
+package idempotency
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// sweepInterval is how often the memory store drops expired records
+const sweepInterval = time.Minute
+
+// MemoryStore keeps records in process memory. Keys are per instance, so
+// retries must reach the same instance to be recognised.
+type MemoryStore struct {
+	mu        sync.Mutex
+	records   map[string]*memoryRecord
+	lastSweep time.Time
+}
+
+type memoryRecord struct {
+	Record
+	expires time.Time
+}
+
+// NewMemoryStore returns an empty in-memory store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{records: make(map[string]*memoryRecord)}
+}
+
+// Begin implements Store
+func (s *MemoryStore) Begin(_ context.Context, key, fingerprint string, ttl time.Duration, now time.Time) (Record, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if now.Sub(s.lastSweep) >= sweepInterval {
+		s.sweep(now)
+	}
+
+	if r, ok := s.records[key]; ok && now.Before(r.expires) {
+		return r.Record, false, nil
+	}
+	s.records[key] = &memoryRecord{Record: Record{Fingerprint: fingerprint}, expires: now.Add(ttl)}
+	return Record{Fingerprint: fingerprint}, true, nil
+}
+
+// Complete implements Store
+func (s *MemoryStore) Complete(_ context.Context, key string, response Response, ttl time.Duration, now time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if r, ok := s.records[key]; ok {
+		r.Response = &response
+		r.expires = now.Add(ttl)
+	}
+	return nil
+}
+
+// Release implements Store
+func (s *MemoryStore) Release(_ context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if r, ok := s.records[key]; ok && r.Response == nil {
+		delete(s.records, key)
+	}
+	return nil
+}
+
+// Len returns the number of records held
+func (s *MemoryStore) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.records)
+}
+
+func (s *MemoryStore) sweep(now time.Time) {
+	for key, r := range s.records {
+		if !now.Before(r.expires) {
+			delete(s.records, key)
+		}
+	}
+	s.lastSweep = now
+}
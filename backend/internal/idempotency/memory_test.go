@@ -0,0 +1,60 @@
+package idempotency
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestMemoryStore(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	start := time.Unix(1_700_000_000, 0)
+	ttl := time.Hour
+
+	if _, claimed, _ := store.Begin(ctx, "k", "a", ttl, start); !claimed {
+		t.Fatal("Expected a new key to be claimed")
+	}
+	record, claimed, _ := store.Begin(ctx, "k", "b", ttl, start)
+	if claimed || record.Fingerprint != "a" || record.Response != nil {
+		t.Errorf("Expected the in-flight record for fingerprint a, got %+v (claimed %v)", record, claimed)
+	}
+
+	response := Response{Status: http.StatusCreated, Header: http.Header{"Location": {"/x/1"}}, Body: []byte(`{"id":1}`)}
+	store.Complete(ctx, "k", response, ttl, start.Add(time.Minute))
+	store.Release(ctx, "k")
+	record, claimed, _ = store.Begin(ctx, "k", "a", ttl, start.Add(time.Hour))
+	if claimed || record.Response == nil || record.Response.Status != http.StatusCreated {
+		t.Errorf("Expected the stored response to survive Release until it expires, got %+v", record)
+	}
+
+	if _, claimed, _ := store.Begin(ctx, "k", "a", ttl, start.Add(time.Minute+ttl)); !claimed {
+		t.Error("Expected an expired key to be claimed again")
+	}
+}
+
+func TestMemoryStoreRelease(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	now := time.Unix(1_700_000_000, 0)
+
+	store.Begin(ctx, "k", "a", time.Hour, now)
+	store.Release(ctx, "k")
+	if _, claimed, _ := store.Begin(ctx, "k", "b", time.Hour, now); !claimed {
+		t.Error("Expected a released key to be claimable")
+	}
+}
+
+func TestMemoryStoreSweepsExpiredRecords(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	start := time.Unix(1_700_000_000, 0)
+
+	store.Begin(ctx, "short", "a", time.Second, start)
+	store.Begin(ctx, "long", "a", time.Hour, start)
+	store.Begin(ctx, "new", "a", time.Hour, start.Add(2*sweepInterval))
+	if store.Len() != 2 {
+		t.Errorf("Expected the expired record to be dropped, got %d records", store.Len())
+	}
+}
@@ -0,0 +1,53 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestParseLevel(t *testing.T) {
+	tests := map[string]slog.Level{
+		"debug": slog.LevelDebug,
+		"info":  slog.LevelInfo,
+		"warn":  slog.LevelWarn,
+		"error": slog.LevelError,
+		"":      slog.LevelInfo,
+		"loud":  slog.LevelInfo,
+	}
+	for name, want := range tests {
+		if got := ParseLevel(name); got != want {
+			t.Errorf("ParseLevel(%q): expected %v, got %v", name, want, got)
+		}
+	}
+}
+
+func TestLevelCanChange(t *testing.T) {
+	var buf bytes.Buffer
+	level := new(slog.LevelVar)
+	level.Set(slog.LevelWarn)
+	logger := New(&buf, level)
+
+	logger.Info("hidden")
+	level.Set(slog.LevelDebug)
+	logger.Info("shown")
+
+	if strings.Contains(buf.String(), "hidden") || !strings.Contains(buf.String(), "shown") {
+		t.Errorf("Expected only records at the current level, got %q", buf.String())
+	}
+}
+
+func TestContext(t *testing.T) {
+	ctx := context.Background()
+	if FromContext(ctx) != slog.Default() || RequestID(ctx) != "" {
+		t.Error("Expected the default logger and no request ID for an empty context")
+	}
+
+	logger := slog.New(slog.NewJSONHandler(&bytes.Buffer{}, nil))
+	ctx = WithRequestID(WithLogger(ctx, logger), "abc")
+	if FromContext(ctx) != logger || RequestID(ctx) != "abc" {
+		t.Error("Expected the stored logger and request ID")
+	}
+}
@@ -0,0 +1,27 @@
+package metrics
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestHandlerServesRuntimeMetrics(t *testing.T) {
+	w := httptest.NewRecorder()
+	Handler(NewRegistry()).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", w.Code)
+	}
+	if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain") {
+		t.Errorf("Expected the Prometheus text format, got %q", ct)
+	}
+	body, _ := io.ReadAll(w.Body)
+	for _, name := range []string{"go_goroutines", "go_memstats_heap_alloc_bytes", "go_gc_duration_seconds"} {
+		if !strings.Contains(string(body), name) {
+			t.Errorf("Expected %s in the output", name)
+		}
+	}
+}
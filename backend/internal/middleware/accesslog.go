@@ -0,0 +1,86 @@
+package middleware
+
+import (
+	"log/slog"
+	"math/rand/v2"
+	"net/http"
+	"slices"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/logging"
+)
+
+// AccessLogConfig configures the access log
+type AccessLogConfig struct {
+	// SampleRate is the fraction of requests logged, from 0 to 1. Server
+	// errors are always logged.
+	SampleRate float64
+	// SkipPaths lists request paths that are never logged, e.g. /health
+	SkipPaths []string
+}
+
+// AccessLog writes one structured record per request. Its settings can be
+// swapped at runtime, e.g. when the configuration is reloaded.
+type AccessLog struct {
+	cfg atomic.Pointer[AccessLogConfig]
+
+	// sample is replaced in tests
+	sample func() float64
+}
+
+// NewAccessLog returns an access log with the given settings
+func NewAccessLog(cfg AccessLogConfig) *AccessLog {
+	a := &AccessLog{sample: rand.Float64}
+	a.SetConfig(cfg)
+	return a
+}
+
+// SetConfig replaces the settings
+func (a *AccessLog) SetConfig(cfg AccessLogConfig) {
+	a.cfg.Store(&cfg)
+}
+
+// Handler returns the gin middleware. Records go to the request logger set
+// up by RequestID, so they carry the request ID. It should run right after
+// RequestID so rejected requests are logged too.
+func (a *AccessLog) Handler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		cfg := a.cfg.Load()
+		status := c.Writer.Status()
+		if slices.Contains(cfg.SkipPaths, c.Request.URL.Path) {
+			return
+		}
+		if status < http.StatusInternalServerError && a.sample() >= cfg.SampleRate {
+			return
+		}
+
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		userID := ""
+		if claims, ok := ClaimsFrom(c); ok {
+			userID = claims.UserID()
+		}
+
+		level := slog.LevelInfo
+		if status >= http.StatusInternalServerError {
+			level = slog.LevelError
+		}
+		logging.FromContext(c.Request.Context()).LogAttrs(c.Request.Context(), level, "request",
+			slog.String("method", c.Request.Method),
+			slog.String("route", route),
+			slog.String("path", c.Request.URL.Path),
+			slog.Int("status", status),
+			slog.Float64("latency_ms", float64(time.Since(start).Microseconds())/1000),
+			slog.Int("bytes", max(c.Writer.Size(), 0)),
+			slog.String("client_ip", c.ClientIP()),
+			slog.String("user_id", userID),
+		)
+	}
+}
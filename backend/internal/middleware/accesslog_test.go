@@ -0,0 +1,93 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func newAccessLogRouter(buf *bytes.Buffer, cfg AccessLogConfig, sample float64) *gin.Engine {
+	accessLog := NewAccessLog(cfg)
+	accessLog.sample = func() float64 { return sample }
+
+	router := gin.New()
+	router.Use(RequestID(slog.New(slog.NewJSONHandler(buf, nil))))
+	router.Use(accessLog.Handler())
+	router.GET("/health", func(c *gin.Context) { c.Status(http.StatusOK) })
+	router.GET("/api/v1/users/:id", NewJWTAuth(testJWTConfig()).Handler(), func(c *gin.Context) {
+		c.String(http.StatusOK, "hello")
+	})
+	router.GET("/fail", func(c *gin.Context) { c.Status(http.StatusInternalServerError) })
+	return router
+}
+
+func TestAccessLogRecord(t *testing.T) {
+	var buf bytes.Buffer
+	router := newAccessLogRouter(&buf, AccessLogConfig{SampleRate: 1}, 0)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/users/42?x=1", nil)
+	req.Header.Set("Authorization", "Bearer "+signToken(t, testSecret, jwt.SigningMethodHS256, nil))
+	req.Header.Set(RequestIDHeader, "req-1")
+	router.ServeHTTP(httptest.NewRecorder(), req)
+
+	var record map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &record); err != nil {
+		t.Fatalf("Expected one JSON record, got %q", buf.String())
+	}
+
+	want := map[string]any{
+		"msg":        "request",
+		"method":     "GET",
+		"route":      "/api/v1/users/:id",
+		"path":       "/api/v1/users/42",
+		"status":     float64(200),
+		"bytes":      float64(5),
+		"user_id":    "42",
+		"request_id": "req-1",
+	}
+	for key, value := range want {
+		if record[key] != value {
+			t.Errorf("Expected %s=%v, got %v", key, value, record[key])
+		}
+	}
+	if _, ok := record["latency_ms"].(float64); !ok {
+		t.Errorf("Expected latency_ms to be a number, got %v", record["latency_ms"])
+	}
+}
+
+func TestAccessLogFiltering(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     AccessLogConfig
+		sample  float64
+		path    string
+		wantLog bool
+	}{
+		{"logged", AccessLogConfig{SampleRate: 1}, 0.99, "/api/v1/users/1", true},
+		{"skipped path", AccessLogConfig{SampleRate: 1, SkipPaths: []string{"/health"}}, 0, "/health", false},
+		{"sampled in", AccessLogConfig{SampleRate: 0.5}, 0.2, "/api/v1/users/1", true},
+		{"sampled out", AccessLogConfig{SampleRate: 0.5}, 0.7, "/api/v1/users/1", false},
+		{"server errors are always logged", AccessLogConfig{SampleRate: 0}, 0.7, "/fail", true},
+		{"unmatched route", AccessLogConfig{SampleRate: 1}, 0, "/nope", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			router := newAccessLogRouter(&buf, tt.cfg, tt.sample)
+			router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, tt.path, nil))
+
+			logged := strings.Contains(buf.String(), `"msg":"request"`)
+			if logged != tt.wantLog {
+				t.Errorf("Expected logged=%v, got %q", tt.wantLog, buf.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,193 @@
+package middleware
+
+import (
+	"errors"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/apierror"
+)
+
+// claimsKey is the gin.Context key holding the verified *Claims
+const claimsKey = "auth.claims"
+
+// Claims are the JWT claims issued and accepted by the API
+type Claims struct {
+	Roles []string `json:"roles,omitempty"`
+	// Scope is a space-separated list of scopes, as in OAuth 2.0
+	Scope string `json:"scope,omitempty"`
+	jwt.RegisteredClaims
+}
+
+// UserID returns the subject of the token
+func (c *Claims) UserID() string {
+	return c.Subject
+}
+
+// HasRole reports whether the token grants role
+func (c *Claims) HasRole(role string) bool {
+	return slices.Contains(c.Roles, role)
+}
+
+// HasScope reports whether the token grants scope
+func (c *Claims) HasScope(scope string) bool {
+	return slices.Contains(strings.Fields(c.Scope), scope)
+}
+
+// JWTConfig configures token verification
+type JWTConfig struct {
+	// Secret signs new tokens and verifies incoming ones
+	Secret string
+	// PreviousSecret is still accepted for verification so tokens issued
+	// before a secret rotation stay valid until they expire
+	PreviousSecret string
+	// Issuer and Audience, when set, must match the iss and aud claims
+	Issuer   string
+	Audience string
+	// ClockSkew is the leeway allowed when checking exp and nbf
+	ClockSkew time.Duration
+}
+
+// JWTAuth verifies HS256 bearer tokens
+type JWTAuth struct {
+	cfg    JWTConfig
+	keys   jwt.VerificationKeySet
+	parser *jwt.Parser
+}
+
+// NewJWTAuth builds a verifier for the given configuration
+func NewJWTAuth(cfg JWTConfig) *JWTAuth {
+	a := &JWTAuth{cfg: cfg}
+	a.keys.Keys = append(a.keys.Keys, []byte(cfg.Secret))
+	if cfg.PreviousSecret != "" {
+		a.keys.Keys = append(a.keys.Keys, []byte(cfg.PreviousSecret))
+	}
+
+	opts := []jwt.ParserOption{
+		jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}),
+		jwt.WithLeeway(cfg.ClockSkew),
+		jwt.WithExpirationRequired(),
+	}
+	if cfg.Issuer != "" {
+		opts = append(opts, jwt.WithIssuer(cfg.Issuer))
+	}
+	if cfg.Audience != "" {
+		opts = append(opts, jwt.WithAudience(cfg.Audience))
+	}
+	a.parser = jwt.NewParser(opts...)
+	return a
+}
+
+// Sign issues a token for claims using the current secret. Issuer and
+// Audience are filled in from the configuration when not already set.
+func (a *JWTAuth) Sign(claims *Claims) (string, error) {
+	if claims.Issuer == "" {
+		claims.Issuer = a.cfg.Issuer
+	}
+	if len(claims.Audience) == 0 && a.cfg.Audience != "" {
+		claims.Audience = jwt.ClaimStrings{a.cfg.Audience}
+	}
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(a.cfg.Secret))
+}
+
+// Verify parses token and checks its signature against the current and
+// previous secret, then its exp, nbf, iss and aud claims
+func (a *JWTAuth) Verify(token string) (*Claims, error) {
+	claims := &Claims{}
+	_, err := a.parser.ParseWithClaims(token, claims, func(*jwt.Token) (any, error) {
+		return a.keys, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
+// Handler returns a middleware that requires a valid bearer token and
+// stores its claims in the context, see ClaimsFrom. Missing or invalid
+// tokens are rejected with 401.
+func (a *JWTAuth) Handler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		token, ok := bearerToken(c.GetHeader("Authorization"))
+		if !ok {
+			c.Header("WWW-Authenticate", `Bearer`)
+			apierror.Abort(c, http.StatusUnauthorized, "missing_token", "a bearer token is required")
+			return
+		}
+
+		claims, err := a.Verify(token)
+		if err != nil {
+			code, message := "invalid_token", "the token is invalid"
+			if errors.Is(err, jwt.ErrTokenExpired) {
+				code, message = "token_expired", "the token has expired"
+			}
+			c.Header("WWW-Authenticate", `Bearer error="invalid_token"`)
+			apierror.Abort(c, http.StatusUnauthorized, code, message)
+			return
+		}
+
+		c.Set(claimsKey, claims)
+		c.Next()
+	}
+}
+
+// ClaimsFrom returns the claims stored by JWTAuth.Handler
+func ClaimsFrom(c *gin.Context) (*Claims, bool) {
+	v, ok := c.Get(claimsKey)
+	if !ok {
+		return nil, false
+	}
+	claims, ok := v.(*Claims)
+	return claims, ok
+}
+
+// RequireRole allows the request if the token grants any of roles. It must
+// run after JWTAuth.Handler.
+func RequireRole(roles ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		claims, ok := ClaimsFrom(c)
+		if !ok {
+			apierror.Abort(c, http.StatusUnauthorized, "missing_token", "a bearer token is required")
+			return
+		}
+		if !slices.ContainsFunc(roles, claims.HasRole) {
+			apierror.Abort(c, http.StatusForbidden, "insufficient_role", "requires role "+strings.Join(roles, " or "))
+			return
+		}
+		c.Next()
+	}
+}
+
+// RequireScope allows the request if the token grants all of scopes. It
+// must run after JWTAuth.Handler.
+func RequireScope(scopes ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		claims, ok := ClaimsFrom(c)
+		if !ok {
+			apierror.Abort(c, http.StatusUnauthorized, "missing_token", "a bearer token is required")
+			return
+		}
+		for _, scope := range scopes {
+			if !claims.HasScope(scope) {
+				c.Header("WWW-Authenticate", `Bearer error="insufficient_scope", scope="`+strings.Join(scopes, " ")+`"`)
+				apierror.Abort(c, http.StatusForbidden, "insufficient_scope", "requires scope "+scope)
+				return
+			}
+		}
+		c.Next()
+	}
+}
+
+// bearerToken extracts the token from an "Authorization: Bearer <token>" header
+func bearerToken(header string) (string, bool) {
+	scheme, token, ok := strings.Cut(header, " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return "", false
+	}
+	token = strings.TrimSpace(token)
+	return token, token != ""
+}
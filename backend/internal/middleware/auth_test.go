@@ -0,0 +1,211 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	testSecret     = "current-secret-current-secret-000"
+	testOldSecret  = "previous-secret-previous-secret-0"
+	testIssuer     = "sum25-backend"
+	testAudience   = "sum25-api"
+	testClockSkew  = 30 * time.Second
+	testOtherValue = "someone-else"
+)
+
+func testJWTConfig() JWTConfig {
+	return JWTConfig{
+		Secret:         testSecret,
+		PreviousSecret: testOldSecret,
+		Issuer:         testIssuer,
+		Audience:       testAudience,
+		ClockSkew:      testClockSkew,
+	}
+}
+
+func signToken(t *testing.T, secret string, method jwt.SigningMethod, mutate func(*Claims)) string {
+	t.Helper()
+	now := time.Now()
+	claims := &Claims{
+		Roles: []string{"user"},
+		Scope: "activities:read meals:read",
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   "42",
+			Issuer:    testIssuer,
+			Audience:  jwt.ClaimStrings{testAudience},
+			IssuedAt:  jwt.NewNumericDate(now),
+			NotBefore: jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(time.Hour)),
+		},
+	}
+	if mutate != nil {
+		mutate(claims)
+	}
+	key := any([]byte(secret))
+	if method == jwt.SigningMethodNone {
+		key = jwt.UnsafeAllowNoneSignatureType
+	}
+	token, err := jwt.NewWithClaims(method, claims).SignedString(key)
+	if err != nil {
+		t.Fatalf("Failed to sign token: %v", err)
+	}
+	return token
+}
+
+func newAuthRouter(guards ...gin.HandlerFunc) *gin.Engine {
+	router := gin.New()
+	handlers := append([]gin.HandlerFunc{NewJWTAuth(testJWTConfig()).Handler()}, guards...)
+	handlers = append(handlers, func(c *gin.Context) {
+		claims, _ := ClaimsFrom(c)
+		c.String(http.StatusOK, claims.UserID())
+	})
+	router.GET("/api/v1/me", handlers...)
+	return router
+}
+
+func TestJWTAuth(t *testing.T) {
+	router := newAuthRouter()
+	hs := jwt.SigningMethodHS256
+
+	tests := []struct {
+		name       string
+		header     string
+		wantStatus int
+		wantCode   string
+	}{
+		{"valid token", "Bearer " + signToken(t, testSecret, hs, nil), http.StatusOK, ""},
+		{"lowercase scheme", "bearer " + signToken(t, testSecret, hs, nil), http.StatusOK, ""},
+		{"previous secret", "Bearer " + signToken(t, testOldSecret, hs, nil), http.StatusOK, ""},
+		{"missing header", "", http.StatusUnauthorized, "missing_token"},
+		{"basic auth", "Basic dXNlcjpwYXNz", http.StatusUnauthorized, "missing_token"},
+		{"malformed token", "Bearer not.a.jwt", http.StatusUnauthorized, "invalid_token"},
+		{"unknown secret", "Bearer " + signToken(t, "some-other-secret", hs, nil), http.StatusUnauthorized, "invalid_token"},
+		{"alg none", "Bearer " + signToken(t, "", jwt.SigningMethodNone, nil), http.StatusUnauthorized, "invalid_token"},
+		{"HS512", "Bearer " + signToken(t, testSecret, jwt.SigningMethodHS512, nil), http.StatusUnauthorized, "invalid_token"},
+		{"expired", "Bearer " + signToken(t, testSecret, hs, func(c *Claims) {
+			c.ExpiresAt = jwt.NewNumericDate(time.Now().Add(-time.Minute))
+		}), http.StatusUnauthorized, "token_expired"},
+		{"expired within clock skew", "Bearer " + signToken(t, testSecret, hs, func(c *Claims) {
+			c.ExpiresAt = jwt.NewNumericDate(time.Now().Add(-testClockSkew / 2))
+		}), http.StatusOK, ""},
+		{"no expiry", "Bearer " + signToken(t, testSecret, hs, func(c *Claims) {
+			c.ExpiresAt = nil
+		}), http.StatusUnauthorized, "invalid_token"},
+		{"not yet valid", "Bearer " + signToken(t, testSecret, hs, func(c *Claims) {
+			c.NotBefore = jwt.NewNumericDate(time.Now().Add(time.Minute))
+		}), http.StatusUnauthorized, "invalid_token"},
+		{"not yet valid within clock skew", "Bearer " + signToken(t, testSecret, hs, func(c *Claims) {
+			c.NotBefore = jwt.NewNumericDate(time.Now().Add(testClockSkew / 2))
+		}), http.StatusOK, ""},
+		{"wrong issuer", "Bearer " + signToken(t, testSecret, hs, func(c *Claims) {
+			c.Issuer = testOtherValue
+		}), http.StatusUnauthorized, "invalid_token"},
+		{"wrong audience", "Bearer " + signToken(t, testSecret, hs, func(c *Claims) {
+			c.Audience = jwt.ClaimStrings{testOtherValue}
+		}), http.StatusUnauthorized, "invalid_token"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/me", nil)
+			if tt.header != "" {
+				req.Header.Set("Authorization", tt.header)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("Expected status %d, got %d: %s", tt.wantStatus, w.Code, w.Body.String())
+			}
+			if tt.wantCode == "" {
+				if w.Body.String() != "42" {
+					t.Errorf("Expected the subject in the context, got %q", w.Body.String())
+				}
+				return
+			}
+			if !strings.Contains(w.Body.String(), `"code":"`+tt.wantCode+`"`) {
+				t.Errorf("Expected error code %s, got %s", tt.wantCode, w.Body.String())
+			}
+			if !strings.HasPrefix(w.Header().Get("WWW-Authenticate"), "Bearer") {
+				t.Errorf("Expected a WWW-Authenticate challenge, got %q", w.Header().Get("WWW-Authenticate"))
+			}
+		})
+	}
+}
+
+func TestJWTAuthSignRoundTrip(t *testing.T) {
+	auth := NewJWTAuth(testJWTConfig())
+	token, err := auth.Sign(&Claims{
+		Roles:            []string{"admin"},
+		RegisteredClaims: jwt.RegisteredClaims{Subject: "7", ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Minute))},
+	})
+	if err != nil {
+		t.Fatalf("Sign failed: %v", err)
+	}
+
+	claims, err := auth.Verify(token)
+	if err != nil {
+		t.Fatalf("Verify failed: %v", err)
+	}
+	if claims.UserID() != "7" || !claims.HasRole("admin") || claims.Issuer != testIssuer {
+		t.Errorf("Unexpected claims after round trip: %+v", claims)
+	}
+}
+
+func TestRequireRoleAndScope(t *testing.T) {
+	hs := jwt.SigningMethodHS256
+	admin := signToken(t, testSecret, hs, func(c *Claims) { c.Roles = []string{"admin"} })
+	user := signToken(t, testSecret, hs, nil)
+
+	tests := []struct {
+		name       string
+		guard      gin.HandlerFunc
+		token      string
+		wantStatus int
+		wantCode   string
+	}{
+		{"role granted", RequireRole("admin"), admin, http.StatusOK, ""},
+		{"any of several roles", RequireRole("admin", "user"), user, http.StatusOK, ""},
+		{"role missing", RequireRole("admin"), user, http.StatusForbidden, "insufficient_role"},
+		{"scope granted", RequireScope("meals:read"), user, http.StatusOK, ""},
+		{"all scopes granted", RequireScope("meals:read", "activities:read"), user, http.StatusOK, ""},
+		{"scope missing", RequireScope("meals:write"), user, http.StatusForbidden, "insufficient_scope"},
+		{"one of the scopes missing", RequireScope("meals:read", "meals:write"), user, http.StatusForbidden, "insufficient_scope"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := newAuthRouter(tt.guard)
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/me", nil)
+			req.Header.Set("Authorization", "Bearer "+tt.token)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("Expected status %d, got %d: %s", tt.wantStatus, w.Code, w.Body.String())
+			}
+			if tt.wantCode != "" && !strings.Contains(w.Body.String(), `"code":"`+tt.wantCode+`"`) {
+				t.Errorf("Expected error code %s, got %s", tt.wantCode, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestGuardsWithoutAuthentication(t *testing.T) {
+	router := gin.New()
+	router.GET("/admin", RequireRole("admin"), func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/admin", nil))
+
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("Expected status 401 without JWTAuth, got %d", w.Code)
+	}
+}
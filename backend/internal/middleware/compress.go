@@ -0,0 +1,250 @@
+package middleware
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/andybalholm/brotli"
+	"github.com/gin-gonic/gin"
+	"github.com/klauspost/compress/zstd"
+)
+
+// CompressConfig configures response compression
+type CompressConfig struct {
+	// Encodings lists the supported content codings in order of preference:
+	// "zstd", "br" and "gzip"
+	Encodings []string
+	// MinSize is the smallest body, in bytes, worth compressing
+	MinSize int
+	// SkipTypes lists content-type prefixes that are already compressed
+	SkipTypes []string
+}
+
+// DefaultCompressConfig returns the settings used by the API
+func DefaultCompressConfig() CompressConfig {
+	return CompressConfig{
+		Encodings: []string{"zstd", "br", "gzip"},
+		MinSize:   1024,
+		SkipTypes: []string{
+			"image/", "video/", "audio/", "font/woff",
+			"application/zip", "application/gzip", "application/x-gzip",
+			"application/zstd", "application/x-brotli", "application/pdf",
+			"text/event-stream",
+		},
+	}
+}
+
+// encoder is a pooled compressor for one content coding
+type encoder interface {
+	io.WriteCloser
+	Flush() error
+	Reset(w io.Writer)
+}
+
+var encoderFactories = map[string]func() encoder{
+	"gzip": func() encoder {
+		return gzip.NewWriter(io.Discard)
+	},
+	"br": func() encoder {
+		return brotli.NewWriterLevel(io.Discard, brotli.DefaultCompression)
+	},
+	"zstd": func() encoder {
+		enc, _ := zstd.NewWriter(io.Discard, zstd.WithEncoderConcurrency(1), zstd.WithEncoderLevel(zstd.SpeedDefault))
+		return enc
+	},
+}
+
+// Compress returns a middleware that compresses responses with the best
+// coding both sides support, negotiated from Accept-Encoding. Bodies are
+// buffered until MinSize bytes so small responses go out unchanged, with
+// their Content-Length. Upgrades, event streams, range requests and
+// responses that already have a Content-Encoding are left alone.
+func Compress(cfg CompressConfig) gin.HandlerFunc {
+	pools := make(map[string]*sync.Pool)
+	for _, name := range cfg.Encodings {
+		if factory, ok := encoderFactories[name]; ok {
+			pools[name] = &sync.Pool{New: func() any { return factory() }}
+		}
+	}
+
+	return func(c *gin.Context) {
+		req := c.Request
+		if req.Header.Get("Upgrade") != "" || req.Header.Get("Range") != "" ||
+			strings.Contains(req.Header.Get("Accept"), "text/event-stream") {
+			c.Next()
+			return
+		}
+
+		c.Writer.Header().Add("Vary", "Accept-Encoding")
+		encoding := negotiateEncoding(req.Header.Get("Accept-Encoding"), cfg.Encodings, pools)
+		if encoding == "" || req.Method == http.MethodHead {
+			c.Next()
+			return
+		}
+
+		w := &compressWriter{ResponseWriter: c.Writer, cfg: &cfg, encoding: encoding, pool: pools[encoding]}
+		c.Writer = w
+		defer func() {
+			w.finish()
+			c.Writer = w.ResponseWriter
+		}()
+		c.Next()
+	}
+}
+
+// negotiateEncoding picks the coding with the highest q-value in the
+// Accept-Encoding header, preferring earlier entries of supported on ties.
+// It returns "" when nothing acceptable is supported.
+func negotiateEncoding(header string, supported []string, pools map[string]*sync.Pool) string {
+	if header == "" {
+		return ""
+	}
+
+	accepted := make(map[string]float64)
+	for _, part := range strings.Split(header, ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		q := 1.0
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			parsed, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				continue
+			}
+			q = parsed
+		}
+		accepted[strings.ToLower(strings.TrimSpace(name))] = q
+	}
+
+	best, bestQ := "", 0.0
+	for _, name := range supported {
+		if pools[name] == nil {
+			continue
+		}
+		q, ok := accepted[name]
+		if !ok {
+			q, ok = accepted["*"]
+		}
+		if ok && q > bestQ {
+			best, bestQ = name, q
+		}
+	}
+	return best
+}
+
+// compressWriter buffers the start of the body until it knows whether the
+// response is worth compressing, then either streams it through a pooled
+// encoder or writes it out as is
+type compressWriter struct {
+	gin.ResponseWriter
+	cfg      *CompressConfig
+	encoding string
+	pool     *sync.Pool
+
+	buf     []byte
+	decided bool
+	enc     encoder
+}
+
+func (w *compressWriter) Write(p []byte) (int, error) {
+	if w.decided {
+		return w.write(p)
+	}
+	w.buf = append(w.buf, p...)
+	if len(w.buf) >= w.cfg.MinSize {
+		if err := w.decide(); err != nil {
+			return 0, err
+		}
+	}
+	return len(p), nil
+}
+
+func (w *compressWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+// Written reports buffered bytes as written so callers don't try to start
+// a second response
+func (w *compressWriter) Written() bool {
+	return len(w.buf) > 0 || w.ResponseWriter.Written()
+}
+
+func (w *compressWriter) Flush() {
+	if !w.decided {
+		w.decide()
+	}
+	if w.enc != nil {
+		w.enc.Flush()
+	}
+	w.ResponseWriter.Flush()
+}
+
+func (w *compressWriter) write(p []byte) (int, error) {
+	if w.enc != nil {
+		return w.enc.Write(p)
+	}
+	return w.ResponseWriter.Write(p)
+}
+
+// decide chooses whether to compress based on what has been buffered and
+// the response headers, then writes out the buffer
+func (w *compressWriter) decide() error {
+	w.decided = true
+	header := w.Header()
+	if w.shouldCompress() {
+		header.Set("Content-Encoding", w.encoding)
+		header.Del("Content-Length")
+		w.enc = w.pool.Get().(encoder)
+		w.enc.Reset(w.ResponseWriter)
+	}
+
+	buf := w.buf
+	w.buf = nil
+	if len(buf) == 0 {
+		return nil
+	}
+	_, err := w.write(buf)
+	return err
+}
+
+func (w *compressWriter) shouldCompress() bool {
+	if len(w.buf) < w.cfg.MinSize {
+		return false
+	}
+	status := w.Status()
+	if status < http.StatusOK || status == http.StatusNoContent || status == http.StatusNotModified {
+		return false
+	}
+
+	header := w.Header()
+	if header.Get("Content-Encoding") != "" {
+		return false
+	}
+	contentType := header.Get("Content-Type")
+	if contentType == "" {
+		contentType = http.DetectContentType(w.buf)
+		header.Set("Content-Type", contentType)
+	}
+	for _, skip := range w.cfg.SkipTypes {
+		if strings.HasPrefix(contentType, skip) {
+			return false
+		}
+	}
+	return true
+}
+
+// finish writes out a body that stayed under MinSize and returns the
+// encoder to its pool
+func (w *compressWriter) finish() {
+	if !w.decided {
+		w.decide()
+	}
+	if w.enc != nil {
+		w.enc.Close()
+		w.enc.Reset(io.Discard)
+		w.pool.Put(w.enc)
+		w.enc = nil
+	}
+}
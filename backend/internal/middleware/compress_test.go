@@ -0,0 +1,185 @@
+package middleware
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+	"github.com/gin-gonic/gin"
+	"github.com/klauspost/compress/zstd"
+)
+
+var largeJSON = `{"items":[` + strings.Repeat(`{"id":1,"name":"activity"},`, 100) + `{}]}`
+
+func newCompressRouter() *gin.Engine {
+	router := gin.New()
+	router.Use(Compress(DefaultCompressConfig()))
+	router.GET("/large", func(c *gin.Context) {
+		c.Data(http.StatusOK, "application/json; charset=utf-8", []byte(largeJSON))
+	})
+	router.GET("/sized", func(c *gin.Context) {
+		c.Header("Content-Length", strconv.Itoa(len(largeJSON)))
+		c.Data(http.StatusOK, "application/json", []byte(largeJSON))
+	})
+	router.GET("/chunks", func(c *gin.Context) {
+		c.Header("Content-Type", "application/json")
+		for i := 0; i < 10; i++ {
+			c.Writer.WriteString(largeJSON[i*len(largeJSON)/10 : (i+1)*len(largeJSON)/10])
+		}
+	})
+	router.GET("/small", func(c *gin.Context) { c.JSON(http.StatusOK, gin.H{"ok": true}) })
+	router.GET("/image", func(c *gin.Context) {
+		c.Data(http.StatusOK, "image/png", bytes.Repeat([]byte{0x89}, 4096))
+	})
+	router.GET("/encoded", func(c *gin.Context) {
+		c.Header("Content-Encoding", "gzip")
+		c.Data(http.StatusOK, "application/json", bytes.Repeat([]byte{1}, 4096))
+	})
+	router.GET("/events", func(c *gin.Context) {
+		c.Header("Content-Type", "text/event-stream")
+		c.Writer.WriteString(strings.Repeat("data: tick\n\n", 200))
+		c.Writer.Flush()
+	})
+	return router
+}
+
+func decode(t *testing.T, encoding string, body []byte) string {
+	t.Helper()
+	var r io.Reader
+	switch encoding {
+	case "gzip":
+		gz, err := gzip.NewReader(bytes.NewReader(body))
+		if err != nil {
+			t.Fatalf("Invalid gzip body: %v", err)
+		}
+		r = gz
+	case "br":
+		r = brotli.NewReader(bytes.NewReader(body))
+	case "zstd":
+		dec, err := zstd.NewReader(bytes.NewReader(body))
+		if err != nil {
+			t.Fatalf("Invalid zstd body: %v", err)
+		}
+		defer dec.Close()
+		r = dec
+	default:
+		return string(body)
+	}
+	out, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatalf("Failed to decode %s body: %v", encoding, err)
+	}
+	return string(out)
+}
+
+func TestCompress(t *testing.T) {
+	router := newCompressRouter()
+
+	tests := []struct {
+		name         string
+		method       string
+		path         string
+		headers      map[string]string
+		wantEncoding string
+		wantVary     bool
+	}{
+		{"gzip", "GET", "/large", map[string]string{"Accept-Encoding": "gzip"}, "gzip", true},
+		{"brotli", "GET", "/large", map[string]string{"Accept-Encoding": "br"}, "br", true},
+		{"zstd", "GET", "/large", map[string]string{"Accept-Encoding": "zstd"}, "zstd", true},
+		{"server preference on ties", "GET", "/large", map[string]string{"Accept-Encoding": "gzip, br, zstd"}, "zstd", true},
+		{"client q-values", "GET", "/large", map[string]string{"Accept-Encoding": "zstd;q=0.2, gzip;q=0.8"}, "gzip", true},
+		{"wildcard", "GET", "/large", map[string]string{"Accept-Encoding": "*"}, "zstd", true},
+		{"refused with q=0", "GET", "/large", map[string]string{"Accept-Encoding": "gzip;q=0"}, "", true},
+		{"no Accept-Encoding", "GET", "/large", nil, "", true},
+		{"unsupported coding", "GET", "/large", map[string]string{"Accept-Encoding": "compress"}, "", true},
+		{"handler-set Content-Length", "GET", "/sized", map[string]string{"Accept-Encoding": "gzip"}, "gzip", true},
+		{"handler-set Content-Length uncompressed", "GET", "/sized", nil, "", true},
+		{"written in chunks", "GET", "/chunks", map[string]string{"Accept-Encoding": "gzip"}, "gzip", true},
+		{"below minimum size", "GET", "/small", map[string]string{"Accept-Encoding": "gzip"}, "", true},
+		{"already compressed type", "GET", "/image", map[string]string{"Accept-Encoding": "gzip"}, "", true},
+		{"existing Content-Encoding", "GET", "/encoded", map[string]string{"Accept-Encoding": "br"}, "gzip", true},
+		{"event stream", "GET", "/events", map[string]string{"Accept-Encoding": "gzip", "Accept": "text/event-stream"}, "", false},
+		{"event stream without Accept", "GET", "/events", map[string]string{"Accept-Encoding": "gzip"}, "", true},
+		{"websocket upgrade", "GET", "/large", map[string]string{"Accept-Encoding": "gzip", "Connection": "Upgrade", "Upgrade": "websocket"}, "", false},
+		{"range request", "GET", "/large", map[string]string{"Accept-Encoding": "gzip", "Range": "bytes=0-10"}, "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(router)
+			defer srv.Close()
+
+			req, _ := http.NewRequest(tt.method, srv.URL+tt.path, nil)
+			for k, v := range tt.headers {
+				req.Header.Set(k, v)
+			}
+			// Disable the transport's transparent gzip so the raw body is seen
+			transport := &http.Transport{DisableCompression: true}
+			resp, err := (&http.Client{Transport: transport}).Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+			body, _ := io.ReadAll(resp.Body)
+
+			if got := resp.Header.Get("Content-Encoding"); got != tt.wantEncoding {
+				t.Errorf("Expected Content-Encoding %q, got %q", tt.wantEncoding, got)
+			}
+			if got := strings.Contains(strings.Join(resp.Header.Values("Vary"), ","), "Accept-Encoding"); got != tt.wantVary {
+				t.Errorf("Expected Vary: Accept-Encoding %v, got %v", tt.wantVary, got)
+			}
+			if resp.ContentLength >= 0 && int(resp.ContentLength) != len(body) {
+				t.Errorf("Content-Length %d does not match body of %d bytes", resp.ContentLength, len(body))
+			}
+			if tt.path == "/large" || tt.path == "/sized" || tt.path == "/chunks" {
+				if got := decode(t, resp.Header.Get("Content-Encoding"), body); got != largeJSON {
+					t.Errorf("Body did not survive the round trip, got %d bytes", len(got))
+				}
+				if tt.wantEncoding != "" && len(body) >= len(largeJSON) {
+					t.Errorf("Expected a smaller body, got %d of %d bytes", len(body), len(largeJSON))
+				}
+			}
+		})
+	}
+}
+
+func TestCompressHead(t *testing.T) {
+	router := newCompressRouter()
+	router.HEAD("/large", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	req := httptest.NewRequest(http.MethodHead, "/large", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if w.Header().Get("Content-Encoding") != "" {
+		t.Error("Expected HEAD responses not to be compressed")
+	}
+}
+
+func TestCompressReusesEncodersSafely(t *testing.T) {
+	router := newCompressRouter()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(encoding string) {
+			defer wg.Done()
+			req := httptest.NewRequest(http.MethodGet, "/large", nil)
+			req.Header.Set("Accept-Encoding", encoding)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+			if got := decode(t, encoding, w.Body.Bytes()); got != largeJSON {
+				t.Errorf("Corrupted %s body from a pooled encoder", encoding)
+			}
+		}([]string{"gzip", "br", "zstd"}[i%3])
+	}
+	wg.Wait()
+}
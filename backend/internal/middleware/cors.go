@@ -1,22 +1,219 @@
 package middleware
 
 import (
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
 	"github.com/gin-gonic/gin"
+	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/apierror"
 )
 
-// CORS middleware to handle Cross-Origin Resource Sharing
-func CORS() gin.HandlerFunc {
-	return gin.HandlerFunc(func(c *gin.Context) {
-		c.Writer.Header().Set("Access-Control-Allow-Origin", "*")
-		c.Writer.Header().Set("Access-Control-Allow-Credentials", "true")
-		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, accept, origin, Cache-Control, X-Requested-With")
-		c.Writer.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS, GET, PUT, DELETE")
+// CORSConfig configures the CORS middleware
+type CORSConfig struct {
+	// AllowedOrigins lists exact origins (https://app.example.com),
+	// wildcard-subdomain patterns (https://*.example.com) or "*" for any.
+	// Origins only matched by "*" never get credentials.
+	AllowedOrigins   []string
+	AllowedMethods   []string
+	AllowedHeaders   []string
+	ExposedHeaders   []string
+	AllowCredentials bool
+	// MaxAge tells browsers how long to cache a preflight response
+	MaxAge time.Duration
+}
+
+// DefaultCORSConfig returns the settings used by the API for the given origins
+func DefaultCORSConfig(origins []string) CORSConfig {
+	return CORSConfig{
+		AllowedOrigins:   origins,
+		AllowedMethods:   []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
+		AllowedHeaders:   []string{"Content-Type", "Content-Length", "Accept-Encoding", "X-CSRF-Token", "Authorization", "Accept", "Origin", "Cache-Control", "X-Requested-With", "X-Request-ID", "Idempotency-Key"},
+		AllowCredentials: true,
+		MaxAge:           10 * time.Minute,
+	}
+}
+
+// CORSPolicy is a CORS middleware whose allowed origins can be swapped at
+// runtime, e.g. when the configuration is reloaded
+type CORSPolicy struct {
+	cfg     CORSConfig
+	origins atomic.Pointer[originMatcher]
+
+	allowMethods string
+	allowHeaders string
+	exposed      string
+	maxAge       string
+}
+
+// NewCORS compiles the configuration into a policy
+func NewCORS(cfg CORSConfig) *CORSPolicy {
+	p := &CORSPolicy{
+		cfg:          cfg,
+		allowMethods: strings.Join(cfg.AllowedMethods, ", "),
+		allowHeaders: strings.Join(cfg.AllowedHeaders, ", "),
+		exposed:      strings.Join(cfg.ExposedHeaders, ", "),
+	}
+	if cfg.MaxAge > 0 {
+		p.maxAge = strconv.Itoa(int(cfg.MaxAge.Seconds()))
+	}
+	p.SetOrigins(cfg.AllowedOrigins)
+	return p
+}
 
-		if c.Request.Method == "OPTIONS" {
-			c.AbortWithStatus(204)
+// CORS returns a middleware for a fixed configuration
+func CORS(cfg CORSConfig) gin.HandlerFunc {
+	return NewCORS(cfg).Handler()
+}
+
+// SetOrigins replaces the allowed origins. It is safe to call while
+// requests are being served.
+func (p *CORSPolicy) SetOrigins(origins []string) {
+	p.origins.Store(newOriginMatcher(origins))
+}
+
+// Handler returns the gin middleware. Listed origins are echoed back in
+// Access-Control-Allow-Origin so credentials keep working; origins only
+// allowed by "*" get a literal "*" and no Access-Control-Allow-Credentials,
+// so no site can make credentialed requests. Disallowed preflights are
+// rejected with 403; other requests from disallowed origins pass through
+// without CORS headers and are blocked by the browser.
+func (p *CORSPolicy) Handler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		header := c.Writer.Header()
+		header.Add("Vary", "Origin")
+
+		origin := c.GetHeader("Origin")
+		if origin == "" {
+			c.Next()
+			return
+		}
+
+		preflight := c.Request.Method == http.MethodOptions && c.GetHeader("Access-Control-Request-Method") != ""
+		match := p.origins.Load().match(origin)
+		allowed := match != noMatch
+
+		if !preflight {
+			if allowed {
+				p.setOriginHeaders(header, origin, match)
+			}
+			c.Next()
 			return
 		}
 
-		c.Next()
-	})
+		header.Add("Vary", "Access-Control-Request-Method")
+		header.Add("Vary", "Access-Control-Request-Headers")
+
+		if !allowed {
+			apierror.Abort(c, http.StatusForbidden, "cors_origin_not_allowed", "origin "+origin+" is not allowed")
+			return
+		}
+		if method := c.GetHeader("Access-Control-Request-Method"); !containsFold(p.cfg.AllowedMethods, method) {
+			apierror.Abort(c, http.StatusForbidden, "cors_method_not_allowed", "method "+method+" is not allowed")
+			return
+		}
+		for _, h := range strings.Split(c.GetHeader("Access-Control-Request-Headers"), ",") {
+			if h = strings.TrimSpace(h); h != "" && !containsFold(p.cfg.AllowedHeaders, h) {
+				apierror.Abort(c, http.StatusForbidden, "cors_header_not_allowed", "header "+h+" is not allowed")
+				return
+			}
+		}
+
+		p.setOriginHeaders(header, origin, match)
+		header.Set("Access-Control-Allow-Methods", p.allowMethods)
+		header.Set("Access-Control-Allow-Headers", p.allowHeaders)
+		if p.maxAge != "" {
+			header.Set("Access-Control-Max-Age", p.maxAge)
+		}
+		c.AbortWithStatus(http.StatusNoContent)
+	}
+}
+
+func (p *CORSPolicy) setOriginHeaders(header http.Header, origin string, match originMatch) {
+	if match == anyMatch {
+		header.Set("Access-Control-Allow-Origin", "*")
+	} else {
+		header.Set("Access-Control-Allow-Origin", origin)
+	}
+	if p.cfg.AllowCredentials && match == listedMatch {
+		header.Set("Access-Control-Allow-Credentials", "true")
+	}
+	if p.exposed != "" {
+		header.Set("Access-Control-Expose-Headers", p.exposed)
+	}
+}
+
+// originMatcher holds compiled origin patterns
+type originMatcher struct {
+	any       bool
+	exact     map[string]bool
+	wildcards []wildcardOrigin
+}
+
+// originMatch is how an origin was allowed
+type originMatch int
+
+const (
+	noMatch originMatch = iota
+	// listedMatch is an exact origin or wildcard-subdomain pattern
+	listedMatch
+	// anyMatch is "*" alone
+	anyMatch
+)
+
+// wildcardOrigin matches any subdomain of suffix, e.g. https://*.example.com
+// matches https://api.example.com but not https://example.com
+type wildcardOrigin struct {
+	scheme string
+	suffix string // ".example.com", including the port if any
+}
+
+func newOriginMatcher(origins []string) *originMatcher {
+	m := &originMatcher{exact: make(map[string]bool)}
+	for _, origin := range origins {
+		origin = strings.ToLower(strings.TrimSpace(origin))
+		switch {
+		case origin == "*":
+			m.any = true
+		case strings.Contains(origin, "://*."):
+			scheme, host, _ := strings.Cut(origin, "://")
+			m.wildcards = append(m.wildcards, wildcardOrigin{scheme: scheme, suffix: strings.TrimPrefix(host, "*")})
+		case origin != "":
+			m.exact[origin] = true
+		}
+	}
+	return m
+}
+
+func (m *originMatcher) match(origin string) originMatch {
+	origin = strings.ToLower(origin)
+	if m.exact[origin] {
+		return listedMatch
+	}
+
+	u, err := url.Parse(origin)
+	if err != nil || u.Host == "" || (u.Scheme != "http" && u.Scheme != "https") {
+		return noMatch
+	}
+	for _, w := range m.wildcards {
+		if u.Scheme == w.scheme && strings.HasSuffix(u.Host, w.suffix) && len(u.Host) > len(w.suffix) {
+			return listedMatch
+		}
+	}
+	if m.any {
+		return anyMatch
+	}
+	return noMatch
+}
+
+func containsFold(values []string, value string) bool {
+	for _, v := range values {
+		if strings.EqualFold(v, value) {
+			return true
+		}
+	}
+	return false
 }
@@ -0,0 +1,226 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func init() {
+	gin.SetMode(gin.TestMode)
+}
+
+func newCORSRouter(cfg CORSConfig) (*gin.Engine, *CORSPolicy) {
+	policy := NewCORS(cfg)
+	router := gin.New()
+	router.Use(policy.Handler())
+	router.GET("/api/v1/ping", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"message": "pong"})
+	})
+	return router, policy
+}
+
+func TestCORS(t *testing.T) {
+	cfg := DefaultCORSConfig([]string{"http://localhost:3000", "https://*.example.com", "https://*.example.org:8443"})
+	cfg.ExposedHeaders = []string{"X-Request-ID"}
+	cfg.MaxAge = 5 * time.Minute
+	router, _ := newCORSRouter(cfg)
+
+	tests := []struct {
+		name           string
+		method         string
+		headers        map[string]string
+		wantStatus     int
+		wantOrigin     string
+		wantCredential bool
+		wantMaxAge     string
+	}{
+		{
+			name:       "no origin",
+			method:     http.MethodGet,
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:           "allowed exact origin",
+			method:         http.MethodGet,
+			headers:        map[string]string{"Origin": "http://localhost:3000"},
+			wantStatus:     http.StatusOK,
+			wantOrigin:     "http://localhost:3000",
+			wantCredential: true,
+		},
+		{
+			name:           "allowed wildcard subdomain",
+			method:         http.MethodGet,
+			headers:        map[string]string{"Origin": "https://app.example.com"},
+			wantStatus:     http.StatusOK,
+			wantOrigin:     "https://app.example.com",
+			wantCredential: true,
+		},
+		{
+			name:       "wildcard does not match apex domain",
+			method:     http.MethodGet,
+			headers:    map[string]string{"Origin": "https://example.com"},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "wildcard does not match lookalike domain",
+			method:     http.MethodGet,
+			headers:    map[string]string{"Origin": "https://app.example.com.evil.io"},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "wildcard requires matching scheme",
+			method:     http.MethodGet,
+			headers:    map[string]string{"Origin": "http://app.example.com"},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:           "wildcard with port",
+			method:         http.MethodGet,
+			headers:        map[string]string{"Origin": "https://api.example.org:8443"},
+			wantStatus:     http.StatusOK,
+			wantOrigin:     "https://api.example.org:8443",
+			wantCredential: true,
+		},
+		{
+			name:       "disallowed origin passes through without headers",
+			method:     http.MethodGet,
+			headers:    map[string]string{"Origin": "https://evil.io"},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:   "allowed preflight",
+			method: http.MethodOptions,
+			headers: map[string]string{
+				"Origin":                         "http://localhost:3000",
+				"Access-Control-Request-Method":  "PATCH",
+				"Access-Control-Request-Headers": "content-type, authorization",
+			},
+			wantStatus:     http.StatusNoContent,
+			wantOrigin:     "http://localhost:3000",
+			wantCredential: true,
+			wantMaxAge:     "300",
+		},
+		{
+			name:   "preflight from disallowed origin",
+			method: http.MethodOptions,
+			headers: map[string]string{
+				"Origin":                        "https://evil.io",
+				"Access-Control-Request-Method": "GET",
+			},
+			wantStatus: http.StatusForbidden,
+		},
+		{
+			name:   "preflight with disallowed method",
+			method: http.MethodOptions,
+			headers: map[string]string{
+				"Origin":                        "http://localhost:3000",
+				"Access-Control-Request-Method": "TRACE",
+			},
+			wantStatus: http.StatusForbidden,
+		},
+		{
+			name:   "preflight with disallowed header",
+			method: http.MethodOptions,
+			headers: map[string]string{
+				"Origin":                         "http://localhost:3000",
+				"Access-Control-Request-Method":  "GET",
+				"Access-Control-Request-Headers": "X-Secret",
+			},
+			wantStatus: http.StatusForbidden,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, "/api/v1/ping", nil)
+			for k, v := range tt.headers {
+				req.Header.Set(k, v)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			if w.Code != tt.wantStatus {
+				t.Errorf("Expected status %d, got %d", tt.wantStatus, w.Code)
+			}
+			if got := w.Header().Get("Access-Control-Allow-Origin"); got != tt.wantOrigin {
+				t.Errorf("Expected Allow-Origin %q, got %q", tt.wantOrigin, got)
+			}
+			if got := w.Header().Get("Access-Control-Allow-Credentials") == "true"; got != tt.wantCredential {
+				t.Errorf("Expected Allow-Credentials %v, got %v", tt.wantCredential, got)
+			}
+			if got := w.Header().Get("Access-Control-Max-Age"); got != tt.wantMaxAge {
+				t.Errorf("Expected Max-Age %q, got %q", tt.wantMaxAge, got)
+			}
+			if !strings.Contains(strings.Join(w.Header().Values("Vary"), ","), "Origin") {
+				t.Error("Expected Vary: Origin")
+			}
+			if tt.wantOrigin != "" && tt.method == http.MethodGet && w.Header().Get("Access-Control-Expose-Headers") != "X-Request-ID" {
+				t.Error("Expected exposed headers on allowed requests")
+			}
+			if tt.wantStatus == http.StatusForbidden && !strings.Contains(w.Body.String(), `"code":"cors_`) {
+				t.Errorf("Expected a JSON error body, got %s", w.Body.String())
+			}
+		})
+	}
+}
+
+func TestCORSNeverSendsWildcardWithCredentials(t *testing.T) {
+	router, _ := newCORSRouter(DefaultCORSConfig([]string{"*", "https://app.example.com"}))
+
+	tests := []struct {
+		name           string
+		origin         string
+		preflight      bool
+		wantOrigin     string
+		wantCredential bool
+	}{
+		{"any origin", "https://anything.io", false, "*", false},
+		{"any origin preflight", "https://anything.io", true, "*", false},
+		{"listed origin", "https://app.example.com", false, "https://app.example.com", true},
+		{"not an origin", "null", false, "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/ping", nil)
+			if tt.preflight {
+				req = httptest.NewRequest(http.MethodOptions, "/api/v1/ping", nil)
+				req.Header.Set("Access-Control-Request-Method", http.MethodPost)
+			}
+			req.Header.Set("Origin", tt.origin)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			if got := w.Header().Get("Access-Control-Allow-Origin"); got != tt.wantOrigin {
+				t.Errorf("Expected Allow-Origin %q, got %q", tt.wantOrigin, got)
+			}
+			if got := w.Header().Get("Access-Control-Allow-Credentials") == "true"; got != tt.wantCredential {
+				t.Errorf("Expected Allow-Credentials %v, got %v", tt.wantCredential, got)
+			}
+		})
+	}
+}
+
+func TestCORSSetOrigins(t *testing.T) {
+	router, policy := newCORSRouter(DefaultCORSConfig([]string{"https://old.example.com"}))
+	policy.SetOrigins([]string{"https://new.example.com"})
+
+	for origin, want := range map[string]string{
+		"https://old.example.com": "",
+		"https://new.example.com": "https://new.example.com",
+	} {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/ping", nil)
+		req.Header.Set("Origin", origin)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		if got := w.Header().Get("Access-Control-Allow-Origin"); got != want {
+			t.Errorf("Origin %s: expected Allow-Origin %q, got %q", origin, want, got)
+		}
+	}
+}
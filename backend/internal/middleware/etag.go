@@ -0,0 +1,155 @@
+package middleware
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ETag answers conditional GET and HEAD requests. Successful responses get
+// a weak ETag hashed from the body unless the handler set one with SetETag;
+// If-None-Match matching it, or If-Modified-Since not before a
+// Last-Modified set with SetLastModified, gets 304 without the body. The
+// body is buffered to hash it, so streaming responses that Flush are passed
+// through without an ETag.
+func ETag() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.Request.Method != http.MethodGet && c.Request.Method != http.MethodHead {
+			c.Next()
+			return
+		}
+
+		w := &etagWriter{ResponseWriter: c.Writer}
+		c.Writer = w
+		defer func() { c.Writer = w.ResponseWriter }()
+		c.Next()
+
+		if w.streaming || w.ResponseWriter.Written() {
+			return
+		}
+		header := w.Header()
+		if w.Status() == http.StatusOK {
+			if header.Get("ETag") == "" {
+				sum := sha256.Sum256(w.buf)
+				header.Set("ETag", `W/"`+hex.EncodeToString(sum[:16])+`"`)
+			}
+			if header.Get("Cache-Control") == "" {
+				header.Set("Cache-Control", "private, no-cache")
+			}
+			if notModified(c.Request, header) {
+				writeNotModified(w.ResponseWriter)
+				return
+			}
+		}
+		if len(w.buf) > 0 {
+			w.ResponseWriter.Write(w.buf)
+		}
+	}
+}
+
+// SetETag sets a weak ETag derived from a resource version, such as a
+// revision number or update time, instead of hashing the body
+func SetETag(c *gin.Context, version string) {
+	c.Header("ETag", `W/"`+strings.ReplaceAll(version, `"`, "")+`"`)
+}
+
+// SetLastModified sets Last-Modified for If-Modified-Since
+func SetLastModified(c *gin.Context, t time.Time) {
+	c.Header("Last-Modified", t.UTC().Format(http.TimeFormat))
+}
+
+// NotModified reports whether the client already has the version described
+// by the ETag and Last-Modified set on the response, answering 304 if so.
+// Handlers call it after SetETag to skip loading and encoding the body.
+func NotModified(c *gin.Context) bool {
+	if !notModified(c.Request, c.Writer.Header()) {
+		return false
+	}
+	writeNotModified(c.Writer)
+	c.Abort()
+	return true
+}
+
+// notModified evaluates If-None-Match, or If-Modified-Since when there is
+// no If-None-Match, against the response headers
+func notModified(req *http.Request, header http.Header) bool {
+	if req.Method != http.MethodGet && req.Method != http.MethodHead {
+		return false
+	}
+	if inm := req.Header.Get("If-None-Match"); inm != "" {
+		etag := header.Get("ETag")
+		return etag != "" && etagMatches(inm, etag)
+	}
+
+	ims, err := http.ParseTime(req.Header.Get("If-Modified-Since"))
+	if err != nil {
+		return false
+	}
+	modified, err := http.ParseTime(header.Get("Last-Modified"))
+	return err == nil && !modified.After(ims)
+}
+
+// etagMatches compares the If-None-Match list with etag using the weak
+// comparison RFC 9110 prescribes for it
+func etagMatches(list, etag string) bool {
+	etag = strings.TrimPrefix(etag, "W/")
+	for _, candidate := range strings.Split(list, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// writeNotModified sends 304 with the validators and caching headers but
+// none describing the omitted body
+func writeNotModified(w gin.ResponseWriter) {
+	header := w.Header()
+	for _, name := range []string{"Content-Type", "Content-Length", "Content-Encoding"} {
+		header.Del(name)
+	}
+	w.WriteHeader(http.StatusNotModified)
+	w.WriteHeaderNow()
+}
+
+// etagWriter holds the body back until the ETag has been checked
+type etagWriter struct {
+	gin.ResponseWriter
+	buf       []byte
+	streaming bool
+}
+
+func (w *etagWriter) Write(p []byte) (int, error) {
+	if w.streaming {
+		return w.ResponseWriter.Write(p)
+	}
+	w.buf = append(w.buf, p...)
+	return len(p), nil
+}
+
+func (w *etagWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+// Written reports buffered bytes as written so callers don't try to start
+// a second response
+func (w *etagWriter) Written() bool {
+	return len(w.buf) > 0 || w.ResponseWriter.Written()
+}
+
+// Flush gives up on the ETag and sends what has been buffered
+func (w *etagWriter) Flush() {
+	if !w.streaming {
+		w.streaming = true
+		if len(w.buf) > 0 {
+			w.ResponseWriter.Write(w.buf)
+			w.buf = nil
+		}
+	}
+	w.ResponseWriter.Flush()
+}
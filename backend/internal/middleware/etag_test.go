@@ -0,0 +1,163 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+var lastChange = time.Date(2025, 7, 1, 12, 0, 0, 0, time.UTC)
+
+func newETagRouter(calls *int) *gin.Engine {
+	router := gin.New()
+	router.Use(ETag())
+	router.GET("/items", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"items": []string{"run", "swim"}})
+	})
+	router.GET("/versioned", func(c *gin.Context) {
+		SetETag(c, "rev-7")
+		SetLastModified(c, lastChange)
+		if NotModified(c) {
+			return
+		}
+		*calls++
+		c.JSON(http.StatusOK, gin.H{"rev": 7})
+	})
+	router.GET("/missing", func(c *gin.Context) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+	})
+	router.GET("/stream", func(c *gin.Context) {
+		c.String(http.StatusOK, "part 1")
+		c.Writer.Flush()
+		c.String(http.StatusOK, "part 2")
+	})
+	return router
+}
+
+func TestETagBodyHash(t *testing.T) {
+	router := newETagRouter(new(int))
+
+	first := httptest.NewRecorder()
+	router.ServeHTTP(first, httptest.NewRequest(http.MethodGet, "/items", nil))
+	etag := first.Header().Get("ETag")
+	if len(etag) < 4 || etag[:3] != `W/"` {
+		t.Fatalf("Expected a weak ETag, got %q", etag)
+	}
+	if first.Header().Get("Cache-Control") != "private, no-cache" {
+		t.Errorf("Expected responses to be revalidated, got Cache-Control %q", first.Header().Get("Cache-Control"))
+	}
+
+	again := httptest.NewRecorder()
+	router.ServeHTTP(again, httptest.NewRequest(http.MethodGet, "/items", nil))
+	if again.Header().Get("ETag") != etag {
+		t.Error("Expected the same body to get the same ETag")
+	}
+
+	tests := []struct {
+		name        string
+		ifNoneMatch string
+		wantStatus  int
+	}{
+		{"matching ETag", etag, http.StatusNotModified},
+		{"strong form of the ETag", etag[2:], http.StatusNotModified},
+		{"ETag in a list", `W/"old", ` + etag, http.StatusNotModified},
+		{"wildcard", "*", http.StatusNotModified},
+		{"stale ETag", `W/"old"`, http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/items", nil)
+			req.Header.Set("If-None-Match", tt.ifNoneMatch)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("Expected status %d, got %d", tt.wantStatus, w.Code)
+			}
+			if tt.wantStatus == http.StatusNotModified {
+				if w.Body.Len() != 0 || w.Header().Get("Content-Type") != "" {
+					t.Errorf("Expected 304 without a body, got %q (%s)", w.Body, w.Header().Get("Content-Type"))
+				}
+				if w.Header().Get("ETag") != etag {
+					t.Errorf("Expected 304 to repeat the ETag, got %q", w.Header().Get("ETag"))
+				}
+			} else if w.Body.String() != first.Body.String() {
+				t.Errorf("Expected the full body, got %s", w.Body)
+			}
+		})
+	}
+}
+
+func TestETagResourceVersion(t *testing.T) {
+	tests := []struct {
+		name      string
+		header    string
+		value     string
+		wantCode  int
+		wantCalls int
+	}{
+		{"no validators", "", "", http.StatusOK, 1},
+		{"matching version", "If-None-Match", `W/"rev-7"`, http.StatusNotModified, 0},
+		{"older version", "If-None-Match", `W/"rev-6"`, http.StatusOK, 1},
+		{"not modified since", "If-Modified-Since", lastChange.Format(http.TimeFormat), http.StatusNotModified, 0},
+		{"modified since", "If-Modified-Since", lastChange.Add(-time.Second).Format(http.TimeFormat), http.StatusOK, 1},
+		{"unparsable date", "If-Modified-Since", "yesterday", http.StatusOK, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := 0
+			router := newETagRouter(&calls)
+			req := httptest.NewRequest(http.MethodGet, "/versioned", nil)
+			if tt.header != "" {
+				req.Header.Set(tt.header, tt.value)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			if w.Code != tt.wantCode {
+				t.Errorf("Expected status %d, got %d", tt.wantCode, w.Code)
+			}
+			if calls != tt.wantCalls {
+				t.Errorf("Expected the body to be built %d times, got %d", tt.wantCalls, calls)
+			}
+			if w.Header().Get("ETag") != `W/"rev-7"` || w.Header().Get("Last-Modified") != lastChange.Format(http.TimeFormat) {
+				t.Errorf("Expected the handler's validators, got %q and %q", w.Header().Get("ETag"), w.Header().Get("Last-Modified"))
+			}
+		})
+	}
+}
+
+func TestETagSkipped(t *testing.T) {
+	router := newETagRouter(new(int))
+	router.POST("/items", func(c *gin.Context) { c.JSON(http.StatusCreated, gin.H{"id": 1}) })
+
+	tests := []struct {
+		name     string
+		method   string
+		path     string
+		wantBody string
+	}{
+		{"error response", http.MethodGet, "/missing", `{"error":"not found"}`},
+		{"streamed response", http.MethodGet, "/stream", "part 1part 2"},
+		{"POST", http.MethodPost, "/items", `{"id":1}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, nil)
+			req.Header.Set("If-None-Match", "*")
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			if w.Code == http.StatusNotModified || w.Header().Get("ETag") != "" {
+				t.Errorf("Expected no ETag handling, got %d with ETag %q", w.Code, w.Header().Get("ETag"))
+			}
+			if w.Body.String() != tt.wantBody {
+				t.Errorf("Expected body %s, got %s", tt.wantBody, w.Body)
+			}
+		})
+	}
+}
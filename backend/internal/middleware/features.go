@@ -0,0 +1,28 @@
+package middleware
+
+import (
+	"slices"
+
+	"github.com/gin-gonic/gin"
+)
+
+// featuresKey is the gin.Context key holding the request's feature flags
+const featuresKey = "features.enabled"
+
+// Features stores the feature flags returned by enabled on each request,
+// so handlers see FEATURES changes made by a reload. A request keeps the
+// flags it started with.
+func Features(enabled func() []string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Set(featuresKey, enabled())
+		c.Next()
+	}
+}
+
+// FeatureEnabled reports whether the flag name was on when the request
+// started. It is false on routes that don't run Features.
+func FeatureEnabled(c *gin.Context, name string) bool {
+	v, _ := c.Get(featuresKey)
+	features, _ := v.([]string)
+	return slices.Contains(features, name)
+}
@@ -0,0 +1,45 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestFeatures(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	features := []string{"beta"}
+
+	router := gin.New()
+	router.GET("/", Features(func() []string { return features }), func(c *gin.Context) {
+		c.String(http.StatusOK, strconv.FormatBool(FeatureEnabled(c, "beta")))
+	})
+	router.GET("/plain", func(c *gin.Context) {
+		c.String(http.StatusOK, strconv.FormatBool(FeatureEnabled(c, "beta")))
+	})
+
+	tests := []struct {
+		name     string
+		path     string
+		features []string
+		want     string
+	}{
+		{"enabled", "/", []string{"beta"}, "true"},
+		{"disabled by a reload", "/", []string{"other"}, "false"},
+		{"route without Features", "/plain", []string{"beta"}, "false"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			features = tt.features
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.path, nil))
+			if w.Body.String() != tt.want {
+				t.Errorf("Expected FeatureEnabled %s, got %s", tt.want, w.Body)
+			}
+		})
+	}
+}
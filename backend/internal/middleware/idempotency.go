@@ -0,0 +1,198 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/apierror"
+	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/idempotency"
+)
+
+const (
+	// IdempotencyKeyHeader carries the client's key for a retryable request
+	IdempotencyKeyHeader = "Idempotency-Key"
+	// IdempotentReplayedHeader is set on responses answered from the store
+	IdempotentReplayedHeader = "Idempotent-Replayed"
+
+	maxIdempotencyKeyLength = 255
+)
+
+// unstoredHeaders are not replayed because outer middleware sets them
+// again for each response
+var unstoredHeaders = []string{"Content-Encoding", "Content-Length"}
+
+// Idempotency answers retried POST and PATCH requests carrying an
+// Idempotency-Key with the response to the first one, so clients on flaky
+// connections can retry without creating duplicates.
+type Idempotency struct {
+	store idempotency.Store
+	key   KeyFunc
+	ttl   time.Duration
+
+	// now is replaced in tests
+	now func() time.Time
+}
+
+// NewIdempotency returns middleware that keeps responses in store for ttl.
+// Keys are scoped by key, normally ByUser, so clients can't see each
+// other's responses.
+func NewIdempotency(store idempotency.Store, key KeyFunc, ttl time.Duration) *Idempotency {
+	return &Idempotency{store: store, key: key, ttl: ttl, now: time.Now}
+}
+
+// Handler returns the gin middleware. The first request with a key is
+// handled and its status, headers and body stored; replays get the stored
+// response with Idempotent-Replayed: true. A replay while the first request
+// is in flight gets 409, and reusing a key for a different request 422.
+// Server errors are not stored so the request can be retried. If the store
+// fails the request is handled as if it had no key.
+func (i *Idempotency) Handler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.GetHeader(IdempotencyKeyHeader)
+		if key == "" || (c.Request.Method != http.MethodPost && c.Request.Method != http.MethodPatch) {
+			c.Next()
+			return
+		}
+		if !validIdempotencyKey(key) {
+			apierror.Abort(c, http.StatusBadRequest, "invalid_idempotency_key",
+				"Idempotency-Key must be 1 to 255 printable ASCII characters")
+			return
+		}
+
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				// BodyLimit answers with 413
+				c.Abort()
+				return
+			}
+			apierror.Abort(c, http.StatusBadRequest, "invalid_body", "could not read the request body")
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+		ctx := context.WithoutCancel(c.Request.Context())
+		storeKey := i.key(c) + ":" + key
+		fingerprint := requestFingerprint(c.Request, body)
+		record, claimed, err := i.store.Begin(ctx, storeKey, fingerprint, i.ttl, i.now())
+		if err != nil {
+			log.Printf("⚠️  Idempotency store failed, handling request without a key: %v", err)
+			c.Next()
+			return
+		}
+
+		switch {
+		case record.Fingerprint != fingerprint:
+			apierror.Abort(c, http.StatusUnprocessableEntity, "idempotency_key_reused",
+				"Idempotency-Key was already used for a different request")
+			return
+		case !claimed && record.Response == nil:
+			apierror.Abort(c, http.StatusConflict, "idempotency_in_progress",
+				"a request with this Idempotency-Key is still being processed")
+			return
+		case !claimed:
+			replay(c, record.Response)
+			return
+		}
+
+		// Release the key unless a response is stored, including when the
+		// handler panics
+		stored := false
+		defer func() {
+			if !stored {
+				if err := i.store.Release(ctx, storeKey); err != nil {
+					log.Printf("⚠️  Failed to release idempotency key: %v", err)
+				}
+			}
+		}()
+
+		before := c.Writer.Header().Clone()
+		recorder := &recordingWriter{ResponseWriter: c.Writer}
+		c.Writer = recorder
+		c.Next()
+		c.Writer = recorder.ResponseWriter
+
+		if !c.Writer.Written() || c.Writer.Status() >= http.StatusInternalServerError {
+			return
+		}
+		response := idempotency.Response{
+			Status: c.Writer.Status(),
+			Header: handlerHeaders(before, c.Writer.Header()),
+			Body:   recorder.body.Bytes(),
+		}
+		if err := i.store.Complete(ctx, storeKey, response, i.ttl, i.now()); err != nil {
+			log.Printf("⚠️  Failed to store idempotent response: %v", err)
+			return
+		}
+		stored = true
+	}
+}
+
+func validIdempotencyKey(key string) bool {
+	if len(key) > maxIdempotencyKeyLength {
+		return false
+	}
+	for i := 0; i < len(key); i++ {
+		if key[i] < 0x20 || key[i] > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
+// requestFingerprint identifies a request by method, path and body
+func requestFingerprint(req *http.Request, body []byte) string {
+	h := sha256.New()
+	io.WriteString(h, req.Method+" "+req.URL.Path+"\n")
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// handlerHeaders returns the headers added or changed since before
+func handlerHeaders(before, after http.Header) http.Header {
+	header := make(http.Header)
+	for name, values := range after {
+		if slices.Contains(unstoredHeaders, name) || slices.Equal(before[name], values) {
+			continue
+		}
+		header[name] = slices.Clone(values)
+	}
+	return header
+}
+
+func replay(c *gin.Context, response *idempotency.Response) {
+	header := c.Writer.Header()
+	for name, values := range response.Header {
+		header[name] = slices.Clone(values)
+	}
+	header.Set(IdempotentReplayedHeader, "true")
+	c.Status(response.Status)
+	c.Writer.Write(response.Body)
+	c.Abort()
+}
+
+// recordingWriter keeps a copy of the response body
+type recordingWriter struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *recordingWriter) Write(p []byte) (int, error) {
+	w.body.Write(p)
+	return w.ResponseWriter.Write(p)
+}
+
+func (w *recordingWriter) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
@@ -0,0 +1,179 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/idempotency"
+)
+
+// newIdempotencyRouter serves POST /items, counting the calls. A request
+// from user "alice" is assumed; release, if set, holds the handler.
+func newIdempotencyRouter(calls *atomic.Int32, release <-chan struct{}) *gin.Engine {
+	idem := NewIdempotency(idempotency.NewMemoryStore(), func(c *gin.Context) string {
+		return "user:" + c.GetHeader("X-User")
+	}, time.Hour)
+
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		c.Header("X-Request-ID", c.GetHeader("X-Trace"))
+	})
+	router.Use(idem.Handler())
+	router.POST("/items", func(c *gin.Context) {
+		n := calls.Add(1)
+		if release != nil {
+			<-release
+		}
+		var body map[string]any
+		if err := c.ShouldBindJSON(&body); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "bad body"})
+			return
+		}
+		c.Header("Location", "/items/1")
+		c.JSON(http.StatusCreated, gin.H{"call": n, "name": body["name"]})
+	})
+	router.POST("/fail", func(c *gin.Context) {
+		calls.Add(1)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "boom"})
+	})
+	router.POST("/panic", func(c *gin.Context) {
+		calls.Add(1)
+		panic("boom")
+	})
+	router.GET("/items", func(c *gin.Context) {
+		calls.Add(1)
+		c.Status(http.StatusOK)
+	})
+	return router
+}
+
+func idempotentRequest(method, path, key, user, body string) *http.Request {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-User", user)
+	if key != "" {
+		req.Header.Set(IdempotencyKeyHeader, key)
+	}
+	return req
+}
+
+func TestIdempotencyReplay(t *testing.T) {
+	var calls atomic.Int32
+	router := newIdempotencyRouter(&calls, nil)
+
+	first := httptest.NewRecorder()
+	router.ServeHTTP(first, idempotentRequest(http.MethodPost, "/items", "k1", "alice", `{"name":"run"}`))
+
+	req := idempotentRequest(http.MethodPost, "/items", "k1", "alice", `{"name":"run"}`)
+	req.Header.Set("X-Trace", "retry")
+	replayed := httptest.NewRecorder()
+	router.ServeHTTP(replayed, req)
+
+	if calls.Load() != 1 {
+		t.Errorf("Expected the handler to run once, ran %d times", calls.Load())
+	}
+	if replayed.Code != http.StatusCreated || replayed.Body.String() != first.Body.String() {
+		t.Errorf("Expected the first response %d %s, got %d %s", first.Code, first.Body, replayed.Code, replayed.Body)
+	}
+	if replayed.Header().Get("Location") != "/items/1" || replayed.Header().Get("Content-Type") != "application/json; charset=utf-8" {
+		t.Errorf("Expected the handler's headers to be replayed, got %v", replayed.Header())
+	}
+	if replayed.Header().Get(IdempotentReplayedHeader) != "true" || first.Header().Get(IdempotentReplayedHeader) != "" {
+		t.Error("Expected only the replay to be marked Idempotent-Replayed")
+	}
+	if replayed.Header().Get("X-Request-ID") != "retry" {
+		t.Errorf("Expected headers of outer middleware to come from the replay, got %q", replayed.Header().Get("X-Request-ID"))
+	}
+}
+
+func TestIdempotencyKeyScope(t *testing.T) {
+	tests := []struct {
+		name      string
+		second    *http.Request
+		wantCode  int
+		wantCalls int32
+	}{
+		{"different body", idempotentRequest(http.MethodPost, "/items", "k1", "alice", `{"name":"swim"}`), http.StatusUnprocessableEntity, 1},
+		{"different method", idempotentRequest(http.MethodPatch, "/items", "k1", "alice", `{"name":"run"}`), http.StatusUnprocessableEntity, 1},
+		{"different user", idempotentRequest(http.MethodPost, "/items", "k1", "bob", `{"name":"run"}`), http.StatusCreated, 2},
+		{"different key", idempotentRequest(http.MethodPost, "/items", "k2", "alice", `{"name":"run"}`), http.StatusCreated, 2},
+		{"no key", idempotentRequest(http.MethodPost, "/items", "", "alice", `{"name":"run"}`), http.StatusCreated, 2},
+		{"GET ignores the key", idempotentRequest(http.MethodGet, "/items", "k1", "alice", ""), http.StatusOK, 2},
+		{"invalid key", idempotentRequest(http.MethodPost, "/items", "k\x01", "alice", `{"name":"run"}`), http.StatusBadRequest, 1},
+		{"key too long", idempotentRequest(http.MethodPost, "/items", strings.Repeat("k", 256), "alice", `{"name":"run"}`), http.StatusBadRequest, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var calls atomic.Int32
+			router := newIdempotencyRouter(&calls, nil)
+			router.ServeHTTP(httptest.NewRecorder(), idempotentRequest(http.MethodPost, "/items", "k1", "alice", `{"name":"run"}`))
+
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, tt.second)
+			if w.Code != tt.wantCode {
+				t.Errorf("Expected status %d, got %d: %s", tt.wantCode, w.Code, w.Body)
+			}
+			if calls.Load() != tt.wantCalls {
+				t.Errorf("Expected %d handler calls, got %d", tt.wantCalls, calls.Load())
+			}
+		})
+	}
+}
+
+func TestIdempotencyConcurrentDuplicate(t *testing.T) {
+	var calls atomic.Int32
+	release := make(chan struct{})
+	router := newIdempotencyRouter(&calls, release)
+
+	var wg sync.WaitGroup
+	first := httptest.NewRecorder()
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		router.ServeHTTP(first, idempotentRequest(http.MethodPost, "/items", "k1", "alice", `{"name":"run"}`))
+	}()
+	for calls.Load() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	duplicate := httptest.NewRecorder()
+	router.ServeHTTP(duplicate, idempotentRequest(http.MethodPost, "/items", "k1", "alice", `{"name":"run"}`))
+	close(release)
+	wg.Wait()
+
+	if duplicate.Code != http.StatusConflict {
+		t.Errorf("Expected 409 while the first request is in flight, got %d", duplicate.Code)
+	}
+	if !strings.Contains(duplicate.Body.String(), "idempotency_in_progress") {
+		t.Errorf("Expected error code idempotency_in_progress, got %s", duplicate.Body)
+	}
+	if first.Code != http.StatusCreated {
+		t.Errorf("Expected the first request to complete, got %d", first.Code)
+	}
+}
+
+func TestIdempotencyRetriesFailures(t *testing.T) {
+	for _, path := range []string{"/fail", "/panic"} {
+		t.Run(path, func(t *testing.T) {
+			var calls atomic.Int32
+			router := newIdempotencyRouter(&calls, nil)
+
+			for i := 0; i < 2; i++ {
+				func() {
+					defer func() { recover() }()
+					router.ServeHTTP(httptest.NewRecorder(), idempotentRequest(http.MethodPost, path, "k1", "alice", `{}`))
+				}()
+			}
+			if calls.Load() != 2 {
+				t.Errorf("Expected a failed request to run again on retry, ran %d times", calls.Load())
+			}
+		})
+	}
+}
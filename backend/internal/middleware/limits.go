@@ -0,0 +1,128 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/apierror"
+)
+
+// Context keys holding the request as it was before BodyLimit and Timeout,
+// so a route can loosen the limits set on its group
+const (
+	originalBodyKey  = "limits.body"
+	parentContextKey = "limits.context"
+	// timeoutKey holds the context of the innermost Timeout, which alone
+	// answers for an expired deadline
+	timeoutKey = "limits.timeout"
+)
+
+// BodyLimit rejects request bodies larger than maxBytes with 413, or lifts
+// the limit when maxBytes is 0. Reads past the limit fail with
+// *http.MaxBytesError, and so does the first read of a body whose declared
+// Content-Length is over it, before anything is read. The 413 is written if
+// the handler did not respond. Applied to a route inside a limited group,
+// the route's limit wins.
+func BodyLimit(maxBytes int64) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		original, ok := c.Get(originalBodyKey)
+		if !ok {
+			original = c.Request.Body
+			c.Set(originalBodyKey, original)
+		}
+		if original == nil || original == http.NoBody || maxBytes <= 0 {
+			if original != nil {
+				c.Request.Body = original.(io.ReadCloser)
+			}
+			c.Next()
+			return
+		}
+
+		// The length is checked on read rather than here, so that a route's
+		// own BodyLimit, which replaces this body, can allow more
+		body := &limitedBody{
+			ReadCloser: http.MaxBytesReader(c.Writer, original.(io.ReadCloser), maxBytes),
+			limit:      maxBytes,
+			declared:   c.Request.ContentLength,
+		}
+		c.Request.Body = body
+		c.Next()
+
+		if body.exceeded && !c.Writer.Written() {
+			abortTooLarge(c, maxBytes)
+		}
+	}
+}
+
+func abortTooLarge(c *gin.Context, maxBytes int64) {
+	apierror.Abort(c, http.StatusRequestEntityTooLarge, "request_too_large",
+		"request body must not exceed "+strconv.FormatInt(maxBytes, 10)+" bytes")
+}
+
+// limitedBody remembers whether the handler hit the limit
+type limitedBody struct {
+	io.ReadCloser
+	limit    int64
+	declared int64
+	exceeded bool
+}
+
+func (b *limitedBody) Read(p []byte) (int, error) {
+	if b.declared > b.limit {
+		b.exceeded = true
+		return 0, &http.MaxBytesError{Limit: b.limit}
+	}
+	n, err := b.ReadCloser.Read(p)
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		b.exceeded = true
+	}
+	return n, err
+}
+
+// Timeout gives the handlers d to finish. The request context is cancelled
+// at the deadline; handlers that give up and return without responding get
+// 504, or 503 if the request was cancelled for another reason such as the
+// server shutting down. Applied to a route inside a group with a timeout,
+// the route's timeout wins.
+//
+// The timeout is cooperative: nothing is written while a handler runs, so
+// one that ignores its context still sends its own late response. Pass
+// c.Request.Context() to every blocking call; the server's WRITE_TIMEOUT
+// is the hard limit.
+func Timeout(d time.Duration) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var parent context.Context
+		if v, ok := c.Get(parentContextKey); ok {
+			parent = v.(context.Context)
+		} else {
+			parent = c.Request.Context()
+			c.Set(parentContextKey, parent)
+		}
+
+		// Keep the values added since the first Timeout but not its deadline,
+		// while still following cancellation of the request itself
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(c.Request.Context()), d)
+		defer cancel()
+		stop := context.AfterFunc(parent, cancel)
+		defer stop()
+		c.Request = c.Request.WithContext(ctx)
+		c.Set(timeoutKey, ctx)
+		c.Next()
+
+		if innermost, _ := c.Get(timeoutKey); c.Writer.Written() || innermost != ctx {
+			return
+		}
+		switch {
+		case errors.Is(ctx.Err(), context.DeadlineExceeded):
+			apierror.Abort(c, http.StatusGatewayTimeout, "deadline_exceeded", "the request took too long to process")
+		case ctx.Err() != nil:
+			apierror.Abort(c, http.StatusServiceUnavailable, "unavailable", "the request was cancelled, retry later")
+		}
+	}
+}
@@ -0,0 +1,141 @@
+package middleware
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/apierror"
+)
+
+func TestBodyLimit(t *testing.T) {
+	router := gin.New()
+	api := router.Group("/api", BodyLimit(10))
+	read := func(c *gin.Context) {
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			return
+		}
+		c.String(http.StatusOK, "%d", len(body))
+	}
+	handled := func(c *gin.Context) {
+		if _, err := io.ReadAll(c.Request.Body); err != nil {
+			apierror.Abort(c, http.StatusBadRequest, "bad_request", err.Error())
+			return
+		}
+		c.Status(http.StatusOK)
+	}
+	api.POST("/small", read)
+	api.POST("/handled", handled)
+	api.POST("/upload", BodyLimit(100), read)
+	api.POST("/unlimited", BodyLimit(0), read)
+
+	tests := []struct {
+		name       string
+		path       string
+		body       string
+		chunked    bool
+		wantStatus int
+		wantBody   string
+	}{
+		{"within the limit", "/api/small", "0123456789", false, http.StatusOK, "10"},
+		{"declared length over the limit", "/api/small", strings.Repeat("x", 11), false, http.StatusRequestEntityTooLarge, "request_too_large"},
+		{"streamed body over the limit", "/api/small", strings.Repeat("x", 11), true, http.StatusRequestEntityTooLarge, "request_too_large"},
+		{"handler responds to the read error", "/api/handled", strings.Repeat("x", 11), true, http.StatusBadRequest, "bad_request"},
+		{"route limit overrides the group", "/api/upload", strings.Repeat("x", 50), true, http.StatusOK, "50"},
+		{"route limit overrides the group for a declared length", "/api/upload", strings.Repeat("x", 50), false, http.StatusOK, "50"},
+		{"route limit still applies", "/api/upload", strings.Repeat("x", 101), true, http.StatusRequestEntityTooLarge, "request_too_large"},
+		{"route limit still applies to a declared length", "/api/upload", strings.Repeat("x", 101), false, http.StatusRequestEntityTooLarge, "request_too_large"},
+		{"zero lifts the limit", "/api/unlimited", strings.Repeat("x", 1000), false, http.StatusOK, "1000"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, tt.path, strings.NewReader(tt.body))
+			if tt.chunked {
+				// Hide the length so only the reader enforces the limit
+				req.ContentLength = -1
+				req.Body = io.NopCloser(strings.NewReader(tt.body))
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("Expected status %d, got %d: %s", tt.wantStatus, w.Code, w.Body.String())
+			}
+			if !strings.Contains(w.Body.String(), tt.wantBody) {
+				t.Errorf("Expected %q in the body, got %q", tt.wantBody, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestTimeout(t *testing.T) {
+	router := gin.New()
+	api := router.Group("/api", Timeout(20*time.Millisecond))
+	wait := func(c *gin.Context) {
+		select {
+		case <-c.Request.Context().Done():
+			return
+		case <-time.After(200 * time.Millisecond):
+			c.Status(http.StatusOK)
+		}
+	}
+	api.GET("/fast", func(c *gin.Context) { c.Status(http.StatusOK) })
+	api.GET("/slow", wait)
+	api.GET("/report", Timeout(time.Second), wait)
+
+	tests := []struct {
+		name       string
+		path       string
+		wantStatus int
+		wantCode   string
+	}{
+		{"finishes in time", "/api/fast", http.StatusOK, ""},
+		{"deadline exceeded", "/api/slow", http.StatusGatewayTimeout, "deadline_exceeded"},
+		{"route timeout overrides the group", "/api/report", http.StatusOK, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("Expected status %d, got %d", tt.wantStatus, w.Code)
+			}
+			if tt.wantCode == "" {
+				return
+			}
+			var body apierror.Body
+			if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil || body.Error.Code != tt.wantCode {
+				t.Errorf("Expected error code %s, got %s", tt.wantCode, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestTimeoutFollowsRequestCancellation(t *testing.T) {
+	router := gin.New()
+	router.GET("/", Timeout(time.Second), func(c *gin.Context) {
+		<-c.Request.Context().Done()
+	})
+
+	// Stands in for the client going away or the server shutting down
+	parent, cancel := context.WithCancel(context.Background())
+	req := httptest.NewRequest(http.MethodGet, "/", nil).WithContext(parent)
+	cancel()
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected status 503 when the request is cancelled, got %d", w.Code)
+	}
+}
@@ -0,0 +1,63 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestHTTPMetrics(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	m := NewHTTPMetrics(reg)
+
+	router := gin.New()
+	router.Use(m.Handler())
+	router.GET("/api/v1/users/:id", func(c *gin.Context) {
+		if c.Param("id") == "0" {
+			c.Status(http.StatusNotFound)
+			return
+		}
+		c.Status(http.StatusOK)
+	})
+
+	for _, path := range []string{"/api/v1/users/1", "/api/v1/users/2", "/api/v1/users/0", "/missing"} {
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+	req := httptest.NewRequest("PROPFIND", "/api/v1/users/1", nil)
+	router.ServeHTTP(httptest.NewRecorder(), req)
+
+	tests := []struct {
+		labels []string
+		want   float64
+	}{
+		{[]string{"GET", "/api/v1/users/:id", "2xx"}, 2},
+		{[]string{"GET", "/api/v1/users/:id", "4xx"}, 1},
+		{[]string{"GET", "unmatched", "4xx"}, 1},
+		{[]string{"OTHER", "unmatched", "4xx"}, 1},
+	}
+	for _, tt := range tests {
+		if got := testutil.ToFloat64(m.requests.WithLabelValues(tt.labels...)); got != tt.want {
+			t.Errorf("requests_total%v: expected %v, got %v", tt.labels, tt.want, got)
+		}
+	}
+
+	if n := testutil.CollectAndCount(m.requests); n != len(tests) {
+		t.Errorf("Expected %d series, one per route template and status class, got %d", len(tests), n)
+	}
+	if got := testutil.ToFloat64(m.inFlight.WithLabelValues("/api/v1/users/:id")); got != 0 {
+		t.Errorf("Expected no requests in flight, got %v", got)
+	}
+
+	const histogram = `sum25_http_request_duration_seconds_count{method="GET",route="/api/v1/users/:id",status="2xx"} 2`
+	w := httptest.NewRecorder()
+	promhttp.HandlerFor(reg, promhttp.HandlerOpts{}).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if !strings.Contains(w.Body.String(), histogram) {
+		t.Errorf("Expected %s in:\n%s", histogram, w.Body.String())
+	}
+}
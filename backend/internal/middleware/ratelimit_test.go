@@ -0,0 +1,133 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/ratelimit"
+)
+
+func TestRateLimiter(t *testing.T) {
+	store := ratelimit.NewMemoryStore()
+	limiter := NewRateLimiter("api", store, ByIP, ratelimit.Limit{Rate: 1, Burst: 2})
+	now := time.Unix(1_700_000_000, 0)
+	limiter.now = func() time.Time { return now }
+
+	router := gin.New()
+	if err := router.SetTrustedProxies([]string{"10.0.0.0/8"}); err != nil {
+		t.Fatal(err)
+	}
+	router.GET("/api/v1/ping", limiter.Handler(), func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	tests := []struct {
+		name          string
+		remoteAddr    string
+		forwardedFor  string
+		wantStatus    int
+		wantRemaining string
+		wantRetry     string
+	}{
+		{"first request", "203.0.113.1:1234", "", http.StatusOK, "1", ""},
+		{"second request", "203.0.113.1:1234", "", http.StatusOK, "0", ""},
+		{"over the limit", "203.0.113.1:1234", "", http.StatusTooManyRequests, "0", "1"},
+		{"spoofed header from untrusted client", "203.0.113.1:1234", "198.51.100.7", http.StatusTooManyRequests, "0", "1"},
+		{"other client", "203.0.113.2:1234", "", http.StatusOK, "1", ""},
+		{"client behind trusted proxy", "10.0.0.5:1234", "198.51.100.7", http.StatusOK, "1", ""},
+		{"same client via another proxy", "10.0.0.6:1234", "198.51.100.7", http.StatusOK, "0", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/ping", nil)
+			req.RemoteAddr = tt.remoteAddr
+			if tt.forwardedFor != "" {
+				req.Header.Set("X-Forwarded-For", tt.forwardedFor)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("Expected status %d, got %d", tt.wantStatus, w.Code)
+			}
+			if got := w.Header().Get("X-RateLimit-Limit"); got != "2" {
+				t.Errorf("Expected X-RateLimit-Limit 2, got %q", got)
+			}
+			if got := w.Header().Get("X-RateLimit-Remaining"); got != tt.wantRemaining {
+				t.Errorf("Expected X-RateLimit-Remaining %q, got %q", tt.wantRemaining, got)
+			}
+			if w.Header().Get("X-RateLimit-Reset") == "" {
+				t.Error("Expected X-RateLimit-Reset to be set")
+			}
+			if got := w.Header().Get("Retry-After"); got != tt.wantRetry {
+				t.Errorf("Expected Retry-After %q, got %q", tt.wantRetry, got)
+			}
+		})
+	}
+}
+
+func TestRateLimiterPerUserAndGroup(t *testing.T) {
+	store := ratelimit.NewMemoryStore()
+	auth := NewJWTAuth(testJWTConfig())
+	apiLimiter := NewRateLimiter("api", store, ByIP, ratelimit.Limit{Rate: 1, Burst: 10})
+	userLimiter := NewRateLimiter("user", store, ByUser, ratelimit.Limit{Rate: 1, Burst: 1})
+
+	router := gin.New()
+	api := router.Group("/api/v1", apiLimiter.Handler())
+	api.GET("/ping", func(c *gin.Context) { c.Status(http.StatusOK) })
+	authed := api.Group("", auth.Handler(), userLimiter.Handler())
+	authed.GET("/me", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	alice := signToken(t, testSecret, jwt.SigningMethodHS256, func(c *Claims) { c.Subject = "alice" })
+	bob := signToken(t, testSecret, jwt.SigningMethodHS256, func(c *Claims) { c.Subject = "bob" })
+
+	request := func(path, token string) int {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	if code := request("/api/v1/me", alice); code != http.StatusOK {
+		t.Fatalf("Expected alice's first request to pass, got %d", code)
+	}
+	if code := request("/api/v1/me", alice); code != http.StatusTooManyRequests {
+		t.Errorf("Expected alice to hit the user limit, got %d", code)
+	}
+	if code := request("/api/v1/me", bob); code != http.StatusOK {
+		t.Errorf("Expected bob to have a separate bucket, got %d", code)
+	}
+	if code := request("/api/v1/ping", ""); code != http.StatusOK {
+		t.Errorf("Expected the user limit not to apply outside its group, got %d", code)
+	}
+}
+
+type failingStore struct{}
+
+func (failingStore) Take(context.Context, string, ratelimit.Limit, time.Time) (ratelimit.Result, error) {
+	return ratelimit.Result{}, errors.New("connection refused")
+}
+
+func TestRateLimiterFailsOpen(t *testing.T) {
+	for name, limiter := range map[string]*RateLimiter{
+		"store error": NewRateLimiter("api", failingStore{}, ByIP, ratelimit.Limit{Rate: 1, Burst: 1}),
+		"disabled":    NewRateLimiter("api", failingStore{}, ByIP, ratelimit.Limit{}),
+	} {
+		router := gin.New()
+		router.GET("/", limiter.Handler(), func(c *gin.Context) { c.Status(http.StatusOK) })
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+		if w.Code != http.StatusOK {
+			t.Errorf("%s: expected the request to pass, got %d", name, w.Code)
+		}
+	}
+}
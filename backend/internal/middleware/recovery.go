@@ -0,0 +1,87 @@
+package middleware
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"runtime/debug"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/apierror"
+	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/errorreport"
+	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/logging"
+)
+
+// scrubbedHeaders are replaced before request headers leave the process
+var scrubbedHeaders = []string{"Authorization", "Proxy-Authorization", "Cookie", "X-Api-Key", "X-Csrf-Token"}
+
+// scrubbedParams are replaced in the query string for the same reason
+var scrubbedParams = []string{"token", "access_token", "refresh_token", "password", "api_key"}
+
+const redacted = "[REDACTED]"
+
+// Recovery turns a panic into a 500 with the JSON error envelope and passes
+// the panic, its stack and the request to reporter. It replaces
+// gin.Recovery and should run after RequestID so both carry the request
+// ID. http.ErrAbortHandler is re-raised so net/http can drop the
+// connection as intended.
+func Recovery(reporter errorreport.ErrorReporter) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		defer func() {
+			recovered := recover()
+			if recovered == nil {
+				return
+			}
+			if err, ok := recovered.(error); ok && errors.Is(err, http.ErrAbortHandler) {
+				panic(recovered)
+			}
+
+			reporter.Report(c.Request.Context(), newPanicEvent(c, recovered, debug.Stack()))
+
+			if c.Writer.Written() {
+				// Too late for an error body; just stop the chain
+				c.Abort()
+				return
+			}
+			apierror.Abort(c, http.StatusInternalServerError, "internal_error", "an unexpected error occurred")
+		}()
+		c.Next()
+	}
+}
+
+func newPanicEvent(c *gin.Context, recovered any, stack []byte) errorreport.Event {
+	headers := c.Request.Header.Clone()
+	for _, name := range scrubbedHeaders {
+		if _, ok := headers[name]; ok {
+			headers[name] = []string{redacted}
+		}
+	}
+
+	event := errorreport.Event{
+		Time:      time.Now().UTC(),
+		Message:   fmt.Sprintf("panic: %v", recovered),
+		Stack:     string(stack),
+		RequestID: logging.RequestID(c.Request.Context()),
+		Method:    c.Request.Method,
+		Route:     c.FullPath(),
+		Path:      c.Request.URL.Path,
+		Query:     scrubQuery(c.Request.URL.Query()),
+		ClientIP:  c.ClientIP(),
+		Headers:   headers,
+	}
+	if claims, ok := ClaimsFrom(c); ok {
+		event.UserID = claims.UserID()
+	}
+	return event
+}
+
+func scrubQuery(query url.Values) string {
+	for _, name := range scrubbedParams {
+		if query.Has(name) {
+			query.Set(name, redacted)
+		}
+	}
+	return query.Encode()
+}
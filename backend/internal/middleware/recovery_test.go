@@ -0,0 +1,115 @@
+package middleware
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/apierror"
+	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/errorreport"
+)
+
+func newRecoveryRouter(reporter errorreport.ErrorReporter) *gin.Engine {
+	router := gin.New()
+	router.Use(RequestID(slog.New(slog.DiscardHandler)))
+	router.Use(Recovery(reporter))
+	router.GET("/boom/:id", func(c *gin.Context) { panic("something broke") })
+	router.GET("/partial", func(c *gin.Context) {
+		c.String(http.StatusOK, "half")
+		panic("after writing")
+	})
+	router.GET("/abort", func(c *gin.Context) { panic(http.ErrAbortHandler) })
+	router.GET("/ok", func(c *gin.Context) { c.Status(http.StatusOK) })
+	return router
+}
+
+func TestRecovery(t *testing.T) {
+	reporter := &errorreport.MemoryReporter{}
+	router := newRecoveryRouter(reporter)
+
+	req := httptest.NewRequest(http.MethodGet, "/boom/7?token=abc&page=2", nil)
+	req.Header.Set(RequestIDHeader, "req-42")
+	req.Header.Set("Authorization", "Bearer secret-token")
+	req.Header.Set("Cookie", "session=secret")
+	req.Header.Set("User-Agent", "test-agent")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusInternalServerError {
+		t.Fatalf("Expected status 500, got %d", w.Code)
+	}
+	var body apierror.Body
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatalf("Expected the JSON error envelope, got %q", w.Body.String())
+	}
+	if body.Error.Code != "internal_error" || body.Error.RequestID != "req-42" {
+		t.Errorf("Unexpected error body: %+v", body.Error)
+	}
+	if strings.Contains(w.Body.String(), "something broke") {
+		t.Error("Expected the panic message to stay out of the response")
+	}
+
+	events := reporter.Events()
+	if len(events) != 1 {
+		t.Fatalf("Expected one reported event, got %d", len(events))
+	}
+	event := events[0]
+	if event.Message != "panic: something broke" || event.RequestID != "req-42" || event.Route != "/boom/:id" || event.Method != "GET" {
+		t.Errorf("Unexpected event metadata: %+v", event)
+	}
+	if !strings.Contains(event.Stack, "recovery_test.go") {
+		t.Error("Expected the stack to point at the panicking handler")
+	}
+	if event.Headers.Get("Authorization") != redacted || event.Headers.Get("Cookie") != redacted {
+		t.Errorf("Expected credentials to be scrubbed, got %v", event.Headers)
+	}
+	if event.Headers.Get("User-Agent") != "test-agent" {
+		t.Error("Expected other headers to be kept")
+	}
+	if strings.Contains(event.Query, "abc") || !strings.Contains(event.Query, "page=2") {
+		t.Errorf("Expected the token parameter to be scrubbed, got %q", event.Query)
+	}
+	if req.Header.Get("Authorization") != "Bearer secret-token" {
+		t.Error("Expected the request itself to be left untouched")
+	}
+}
+
+func TestRecoveryAfterWrite(t *testing.T) {
+	reporter := &errorreport.MemoryReporter{}
+	w := httptest.NewRecorder()
+	newRecoveryRouter(reporter).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/partial", nil))
+
+	if w.Body.String() != "half" {
+		t.Errorf("Expected the partial response to be left alone, got %q", w.Body.String())
+	}
+	if len(reporter.Events()) != 1 {
+		t.Error("Expected the panic to be reported")
+	}
+}
+
+func TestRecoveryReraisesAbortHandler(t *testing.T) {
+	reporter := &errorreport.MemoryReporter{}
+	defer func() {
+		if recover() != http.ErrAbortHandler {
+			t.Error("Expected http.ErrAbortHandler to propagate")
+		}
+		if len(reporter.Events()) != 0 {
+			t.Error("Expected an aborted handler not to be reported")
+		}
+	}()
+	newRecoveryRouter(reporter).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/abort", nil))
+}
+
+func TestRecoveryPassesThrough(t *testing.T) {
+	reporter := &errorreport.MemoryReporter{}
+	w := httptest.NewRecorder()
+	newRecoveryRouter(reporter).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/ok", nil))
+
+	if w.Code != http.StatusOK || len(reporter.Events()) != 0 {
+		t.Errorf("Expected a normal response and no events, got %d and %d events", w.Code, len(reporter.Events()))
+	}
+}
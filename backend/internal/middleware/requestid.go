@@ -0,0 +1,51 @@
+package middleware
+
+import (
+	"log/slog"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/logging"
+)
+
+// RequestIDHeader carries the request ID in both directions
+const RequestIDHeader = "X-Request-ID"
+
+// maxRequestIDLength bounds IDs accepted from clients
+const maxRequestIDLength = 128
+
+// RequestID accepts the caller's X-Request-ID, or generates one, and
+// echoes it in the response. The ID and a logger carrying it are stored in
+// the request context; handlers fetch them with logging.RequestID and
+// logging.FromContext. It should be the first middleware.
+func RequestID(logger *slog.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.GetHeader(RequestIDHeader)
+		if !validRequestID(id) {
+			id = uuid.NewString()
+		}
+		c.Header(RequestIDHeader, id)
+
+		ctx := logging.WithRequestID(c.Request.Context(), id)
+		ctx = logging.WithLogger(ctx, logger.With("request_id", id))
+		c.Request = c.Request.WithContext(ctx)
+		c.Next()
+	}
+}
+
+// validRequestID only lets through short IDs made of characters that are
+// safe to put in log lines and headers
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for _, r := range id {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+		case r == '-', r == '_', r == '.', r == ':':
+		default:
+			return false
+		}
+	}
+	return true
+}
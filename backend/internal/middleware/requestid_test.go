@@ -0,0 +1,68 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/logging"
+)
+
+func TestRequestID(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, nil))
+
+	router := gin.New()
+	router.Use(RequestID(logger))
+	router.GET("/", func(c *gin.Context) {
+		logging.FromContext(c.Request.Context()).Info("handled")
+		c.String(http.StatusOK, logging.RequestID(c.Request.Context()))
+	})
+
+	tests := []struct {
+		name     string
+		incoming string
+		keep     bool
+	}{
+		{"generated when missing", "", false},
+		{"accepted from caller", "abc-123_x.y:z", true},
+		{"replaced when too long", strings.Repeat("a", maxRequestIDLength+1), false},
+		{"replaced when unsafe", "id with spaces\n", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			buf.Reset()
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.incoming != "" {
+				req.Header.Set(RequestIDHeader, tt.incoming)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			id := w.Header().Get(RequestIDHeader)
+			if id == "" || id != w.Body.String() {
+				t.Fatalf("Expected the response header and context to share the ID, got %q and %q", id, w.Body.String())
+			}
+			if tt.keep && id != tt.incoming {
+				t.Errorf("Expected the caller's ID %q, got %q", tt.incoming, id)
+			}
+			if !tt.keep && id == tt.incoming {
+				t.Errorf("Expected a generated ID, got the caller's %q", id)
+			}
+
+			var record map[string]any
+			if err := json.Unmarshal(buf.Bytes(), &record); err != nil {
+				t.Fatalf("Expected a JSON log record, got %q", buf.String())
+			}
+			if record["request_id"] != id {
+				t.Errorf("Expected the handler's logger to carry request_id %q, got %v", id, record["request_id"])
+			}
+		})
+	}
+}
@@ -0,0 +1,143 @@
+package middleware
+
+import (
+	"net/http"
+	"net/netip"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/csp"
+)
+
+// cspNonceKey is the gin.Context key holding the request's CSP nonce
+const cspNonceKey = "security.nonce"
+
+// SecurityConfig configures the security headers. Empty values leave the
+// corresponding header out.
+type SecurityConfig struct {
+	// HSTSMaxAge enables Strict-Transport-Security on HTTPS requests
+	HSTSMaxAge            time.Duration
+	HSTSIncludeSubdomains bool
+	HSTSPreload           bool
+	// TrustedProxies lists the proxies (IPs or CIDRs) whose
+	// X-Forwarded-Proto is believed when deciding whether a request came
+	// over HTTPS
+	TrustedProxies []string
+
+	FrameOptions      string
+	ReferrerPolicy    string
+	PermissionsPolicy string
+
+	// CSP is rendered per request, with a fresh nonce if it uses csp.Nonce
+	CSP *csp.Policy
+	// CSPReportOnly sends the policy as Content-Security-Policy-Report-Only
+	// so violations are reported but nothing is blocked
+	CSPReportOnly bool
+}
+
+// DefaultSecurityConfig returns the settings used by the API
+func DefaultSecurityConfig() SecurityConfig {
+	return SecurityConfig{
+		HSTSMaxAge:            365 * 24 * time.Hour,
+		HSTSIncludeSubdomains: true,
+		FrameOptions:          "DENY",
+		ReferrerPolicy:        "strict-origin-when-cross-origin",
+		PermissionsPolicy:     "camera=(), microphone=(), geolocation=()",
+		CSP: csp.New().
+			Set(csp.DefaultSrc, csp.Self).
+			Set(csp.ScriptSrc, csp.Self, csp.Nonce, csp.StrictDynamic).
+			Set(csp.StyleSrc, csp.Self, csp.Nonce).
+			Set(csp.ObjectSrc, csp.None).
+			Set(csp.BaseURI, csp.None).
+			Set(csp.FrameAncestors, csp.None),
+	}
+}
+
+// SecurityHeaders returns a middleware that sets the configured security
+// headers on every response
+func SecurityHeaders(cfg SecurityConfig) gin.HandlerFunc {
+	var proxies []netip.Prefix
+	for _, proxy := range cfg.TrustedProxies {
+		// Entries are validated with the configuration
+		if prefix, err := netip.ParsePrefix(proxy); err == nil {
+			proxies = append(proxies, prefix)
+		} else if addr, err := netip.ParseAddr(proxy); err == nil {
+			proxies = append(proxies, netip.PrefixFrom(addr, addr.BitLen()))
+		}
+	}
+
+	hsts := ""
+	if cfg.HSTSMaxAge > 0 {
+		hsts = "max-age=" + strconv.Itoa(int(cfg.HSTSMaxAge.Seconds()))
+		if cfg.HSTSIncludeSubdomains {
+			hsts += "; includeSubDomains"
+		}
+		if cfg.HSTSPreload {
+			hsts += "; preload"
+		}
+	}
+
+	cspHeader := "Content-Security-Policy"
+	if cfg.CSPReportOnly {
+		cspHeader = "Content-Security-Policy-Report-Only"
+	}
+	useNonce := cfg.CSP != nil && cfg.CSP.UsesNonce()
+
+	return func(c *gin.Context) {
+		header := c.Writer.Header()
+		header.Set("X-Content-Type-Options", "nosniff")
+		if hsts != "" && isHTTPS(c.Request, proxies) {
+			header.Set("Strict-Transport-Security", hsts)
+		}
+		if cfg.FrameOptions != "" {
+			header.Set("X-Frame-Options", cfg.FrameOptions)
+		}
+		if cfg.ReferrerPolicy != "" {
+			header.Set("Referrer-Policy", cfg.ReferrerPolicy)
+		}
+		if cfg.PermissionsPolicy != "" {
+			header.Set("Permissions-Policy", cfg.PermissionsPolicy)
+		}
+		if cfg.CSP != nil {
+			nonce := ""
+			if useNonce {
+				nonce = csp.NewNonce()
+				c.Set(cspNonceKey, nonce)
+			}
+			header.Set(cspHeader, cfg.CSP.Header(nonce))
+		}
+		c.Next()
+	}
+}
+
+// CSPNonce returns the nonce to put on inline <script> and <style> tags,
+// or "" if the policy has none
+func CSPNonce(c *gin.Context) string {
+	return c.GetString(cspNonceKey)
+}
+
+// isHTTPS reports whether the request reached us, or a trusted proxy in
+// front of us, over TLS
+func isHTTPS(r *http.Request, proxies []netip.Prefix) bool {
+	if r.TLS != nil {
+		return true
+	}
+	proto := r.Header.Get("X-Forwarded-Proto")
+	if !strings.EqualFold(strings.TrimSpace(proto), "https") {
+		return false
+	}
+
+	addrPort, err := netip.ParseAddrPort(r.RemoteAddr)
+	if err != nil {
+		return false
+	}
+	remote := addrPort.Addr().Unmap()
+	for _, prefix := range proxies {
+		if prefix.Contains(remote) {
+			return true
+		}
+	}
+	return false
+}
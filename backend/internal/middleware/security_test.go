@@ -0,0 +1,127 @@
+package middleware
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/csp"
+)
+
+func TestSecurityHeaders(t *testing.T) {
+	cfg := DefaultSecurityConfig()
+	cfg.TrustedProxies = []string{"10.0.0.0/8", "192.168.1.1"}
+	router := gin.New()
+	router.Use(SecurityHeaders(cfg))
+	router.GET("/", func(c *gin.Context) { c.String(http.StatusOK, CSPNonce(c)) })
+
+	tests := []struct {
+		name       string
+		remoteAddr string
+		tls        bool
+		proto      string
+		wantHSTS   bool
+	}{
+		{"plain HTTP", "203.0.113.1:1234", false, "", false},
+		{"direct TLS", "203.0.113.1:1234", true, "", true},
+		{"forwarded HTTPS from trusted proxy", "10.1.2.3:1234", false, "https", true},
+		{"forwarded HTTPS from trusted proxy IP", "192.168.1.1:1234", false, "HTTPS", true},
+		{"forwarded HTTPS from untrusted client", "203.0.113.1:1234", false, "https", false},
+		{"forwarded HTTP from trusted proxy", "10.1.2.3:1234", false, "http", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.RemoteAddr = tt.remoteAddr
+			if tt.tls {
+				req.TLS = &tls.ConnectionState{}
+			}
+			if tt.proto != "" {
+				req.Header.Set("X-Forwarded-Proto", tt.proto)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			hsts := w.Header().Get("Strict-Transport-Security")
+			if (hsts != "") != tt.wantHSTS {
+				t.Errorf("Expected HSTS %v, got %q", tt.wantHSTS, hsts)
+			}
+			if tt.wantHSTS && hsts != "max-age=31536000; includeSubDomains" {
+				t.Errorf("Unexpected HSTS value %q", hsts)
+			}
+
+			for header, want := range map[string]string{
+				"X-Content-Type-Options": "nosniff",
+				"X-Frame-Options":        "DENY",
+				"Referrer-Policy":        "strict-origin-when-cross-origin",
+				"Permissions-Policy":     "camera=(), microphone=(), geolocation=()",
+			} {
+				if got := w.Header().Get(header); got != want {
+					t.Errorf("Expected %s %q, got %q", header, want, got)
+				}
+			}
+
+			nonce := w.Body.String()
+			policy := w.Header().Get("Content-Security-Policy")
+			if nonce == "" || !strings.Contains(policy, "script-src 'self' 'nonce-"+nonce+"'") {
+				t.Errorf("Expected the request nonce %q in the policy, got %q", nonce, policy)
+			}
+		})
+	}
+}
+
+func TestSecurityHeadersFreshNoncePerRequest(t *testing.T) {
+	router := gin.New()
+	router.Use(SecurityHeaders(DefaultSecurityConfig()))
+	router.GET("/", func(c *gin.Context) { c.String(http.StatusOK, CSPNonce(c)) })
+
+	seen := make(map[string]bool)
+	for i := 0; i < 5; i++ {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+		if seen[w.Body.String()] {
+			t.Fatalf("Nonce %q was reused", w.Body.String())
+		}
+		seen[w.Body.String()] = true
+	}
+}
+
+func TestSecurityHeadersOptions(t *testing.T) {
+	cfg := SecurityConfig{
+		HSTSMaxAge:    time.Hour,
+		HSTSPreload:   true,
+		CSP:           csp.New().Set(csp.DefaultSrc, csp.None).Set(csp.ReportURI, "/csp-report"),
+		CSPReportOnly: true,
+	}
+	router := gin.New()
+	router.Use(SecurityHeaders(cfg))
+	router.GET("/", func(c *gin.Context) { c.String(http.StatusOK, CSPNonce(c)) })
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.TLS = &tls.ConnectionState{}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if got := w.Header().Get("Strict-Transport-Security"); got != "max-age=3600; preload" {
+		t.Errorf("Unexpected HSTS value %q", got)
+	}
+	if w.Header().Get("Content-Security-Policy") != "" {
+		t.Error("Expected no enforced policy in report-only mode")
+	}
+	if got := w.Header().Get("Content-Security-Policy-Report-Only"); got != "default-src 'none'; report-uri /csp-report" {
+		t.Errorf("Unexpected report-only policy %q", got)
+	}
+	if w.Body.String() != "" {
+		t.Error("Expected no nonce for a policy that doesn't use one")
+	}
+	for _, header := range []string{"X-Frame-Options", "Referrer-Policy", "Permissions-Policy"} {
+		if w.Header().Get(header) != "" {
+			t.Errorf("Expected %s to be left out when not configured", header)
+		}
+	}
+}
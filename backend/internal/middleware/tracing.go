@@ -0,0 +1,61 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/logging"
+	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Tracing records a server span for each request, continuing the trace of
+// the caller when it sends a W3C traceparent header. The span carries the
+// request ID, and trace_id and span_id are added to the request's logger so
+// log lines can be matched to traces. It must run after RequestID and
+// before Recovery, so panics are recorded as 500s.
+func Tracing(provider trace.TracerProvider) gin.HandlerFunc {
+	tracer := provider.Tracer(tracing.Name)
+	return func(c *gin.Context) {
+		ctx := tracing.Propagator.Extract(c.Request.Context(), propagation.HeaderCarrier(c.Request.Header))
+		method := methodLabel(c.Request.Method)
+		ctx, span := tracer.Start(ctx, method,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				semconv.HTTPRequestMethodKey.String(method),
+				semconv.URLPath(c.Request.URL.Path),
+				semconv.ClientAddress(c.ClientIP()),
+				semconv.UserAgentOriginal(c.Request.UserAgent()),
+			),
+		)
+		defer span.End()
+
+		if id := logging.RequestID(ctx); id != "" {
+			span.SetAttributes(attribute.String("request.id", id))
+		}
+		if sc := span.SpanContext(); sc.IsValid() {
+			logger := logging.FromContext(ctx).With("trace_id", sc.TraceID().String(), "span_id", sc.SpanID().String())
+			ctx = logging.WithLogger(ctx, logger)
+		}
+		c.Request = c.Request.WithContext(ctx)
+
+		c.Next()
+
+		if route := c.FullPath(); route != "" {
+			span.SetName(method + " " + route)
+			span.SetAttributes(semconv.HTTPRoute(route))
+		}
+		status := c.Writer.Status()
+		span.SetAttributes(semconv.HTTPResponseStatusCode(status))
+		if status >= http.StatusInternalServerError {
+			span.SetStatus(codes.Error, http.StatusText(status))
+		}
+		if claims, ok := ClaimsFrom(c); ok && claims.UserID() != "" {
+			span.SetAttributes(attribute.String("enduser.id", claims.UserID()))
+		}
+	}
+}
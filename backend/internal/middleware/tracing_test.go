@@ -0,0 +1,108 @@
+package middleware
+
+import (
+	"bytes"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/logging"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func newTracingRouter(logs *bytes.Buffer) (*gin.Engine, *tracetest.SpanRecorder) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+
+	router := gin.New()
+	router.Use(RequestID(slog.New(slog.NewJSONHandler(logs, nil))))
+	router.Use(Tracing(provider))
+	router.GET("/items/:id", func(c *gin.Context) {
+		logging.FromContext(c.Request.Context()).Info("loading item")
+		c.Status(http.StatusOK)
+	})
+	router.GET("/fail", func(c *gin.Context) { c.Status(http.StatusServiceUnavailable) })
+	return router, recorder
+}
+
+func spanAttribute(span sdktrace.ReadOnlySpan, key attribute.Key) attribute.Value {
+	for _, kv := range span.Attributes() {
+		if kv.Key == key {
+			return kv.Value
+		}
+	}
+	return attribute.Value{}
+}
+
+func TestTracingServerSpan(t *testing.T) {
+	var logs bytes.Buffer
+	router, recorder := newTracingRouter(&logs)
+
+	req := httptest.NewRequest(http.MethodGet, "/items/42", nil)
+	req.Header.Set(RequestIDHeader, "req-1")
+	router.ServeHTTP(httptest.NewRecorder(), req)
+
+	spans := recorder.Ended()
+	if len(spans) != 1 {
+		t.Fatalf("Expected one span, got %d", len(spans))
+	}
+	span := spans[0]
+	if span.Name() != "GET /items/:id" || span.SpanKind() != trace.SpanKindServer {
+		t.Errorf("Expected server span \"GET /items/:id\", got %s %q", span.SpanKind(), span.Name())
+	}
+	if span.Parent().IsValid() {
+		t.Error("Expected a new trace without a traceparent header")
+	}
+	for key, want := range map[attribute.Key]string{
+		"http.route":                "/items/:id",
+		"url.path":                  "/items/42",
+		"request.id":                "req-1",
+		"http.response.status_code": "200",
+	} {
+		if got := spanAttribute(span, key).Emit(); got != want {
+			t.Errorf("Expected %s %q, got %q", key, want, got)
+		}
+	}
+
+	traceID := span.SpanContext().TraceID().String()
+	if !strings.Contains(logs.String(), `"trace_id":"`+traceID+`"`) || !strings.Contains(logs.String(), `"request_id":"req-1"`) {
+		t.Errorf("Expected the log line to carry the request and trace IDs, got %s", logs.String())
+	}
+}
+
+func TestTracingContinuesCallerTrace(t *testing.T) {
+	router, recorder := newTracingRouter(&bytes.Buffer{})
+
+	req := httptest.NewRequest(http.MethodGet, "/items/42", nil)
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	router.ServeHTTP(httptest.NewRecorder(), req)
+
+	span := recorder.Ended()[0]
+	if got := span.SpanContext().TraceID().String(); got != "4bf92f3577b34da6a3ce929d0e0e4736" {
+		t.Errorf("Expected the caller's trace ID, got %s", got)
+	}
+	if got := span.Parent().SpanID().String(); got != "00f067aa0ba902b7" || !span.Parent().IsRemote() {
+		t.Errorf("Expected the caller's span as remote parent, got %s", got)
+	}
+}
+
+func TestTracingServerErrors(t *testing.T) {
+	router, recorder := newTracingRouter(&bytes.Buffer{})
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/fail", nil))
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/missing", nil))
+
+	spans := recorder.Ended()
+	if spans[0].Status().Code != codes.Error {
+		t.Error("Expected a 503 to mark the span as failed")
+	}
+	if spans[1].Name() != "GET" || spans[1].Status().Code == codes.Error {
+		t.Errorf("Expected an unmatched route to keep the method as name and not fail, got %q", spans[1].Name())
+	}
+}
@@ -0,0 +1,82 @@
+package migrate
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrVersionExists is returned by Create when the target version is taken
+var ErrVersionExists = errors.New("migration version already exists")
+
+var (
+	nameSanitizer = regexp.MustCompile(`[^a-z0-9]+`)
+	// versionPrefix matches any file that claims a version, including ones
+	// Load would skip, so Create never reuses their number
+	versionPrefix = regexp.MustCompile(`^(\d+)_`)
+)
+
+// Create writes an empty NNNN_name.up.sql / NNNN_name.down.sql pair into dir,
+// numbered one past the highest existing version, and returns both paths.
+// It never overwrites an existing file.
+func Create(dir, name string, now time.Time) (up, down string, err error) {
+	slug := strings.Trim(nameSanitizer.ReplaceAllString(strings.ToLower(name), "_"), "_")
+	if slug == "" {
+		return "", "", fmt.Errorf("invalid migration name %q", name)
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return "", "", fmt.Errorf("read migrations directory: %w", err)
+	}
+
+	var latest uint64
+	for _, entry := range entries {
+		match := versionPrefix.FindStringSubmatch(entry.Name())
+		if match == nil {
+			continue
+		}
+		version, err := strconv.ParseUint(match[1], 10, 64)
+		if err != nil {
+			return "", "", fmt.Errorf("migration %s: invalid version: %w", entry.Name(), err)
+		}
+		latest = max(latest, version)
+	}
+
+	version := latest + 1
+	base := fmt.Sprintf("%04d_%s", version, slug)
+	up = filepath.Join(dir, base+".up.sql")
+	down = filepath.Join(dir, base+".down.sql")
+
+	header := fmt.Sprintf("-- %s (created %s)\n", base, now.UTC().Format(time.RFC3339))
+	if err := writeNew(up, header+"-- Write the schema change here\n"); err != nil {
+		return "", "", err
+	}
+	if err := writeNew(down, header+"-- Write the statements that revert the up migration here\n"); err != nil {
+		os.Remove(up)
+		return "", "", err
+	}
+
+	return up, down, nil
+}
+
+// writeNew creates path with the given contents, failing if it already exists
+func writeNew(path, contents string) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+	if errors.Is(err, os.ErrExist) {
+		return fmt.Errorf("%w: %s", ErrVersionExists, path)
+	}
+	if err != nil {
+		return fmt.Errorf("create %s: %w", path, err)
+	}
+	if _, err := f.WriteString(contents); err != nil {
+		f.Close()
+		return fmt.Errorf("write %s: %w", path, err)
+	}
+	return f.Close()
+}
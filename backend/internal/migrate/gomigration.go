@@ -0,0 +1,64 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sync"
+
+	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/config"
+)
+
+// GoFunc is a migration step written in Go. It runs inside the same
+// transaction that records the version, so returning an error rolls back
+// everything it did.
+type GoFunc func(ctx context.Context, tx *sql.Tx, cfg *config.Config) error
+
+var (
+	registryMu sync.Mutex
+	registry   = make(map[uint64]*Migration)
+)
+
+// Register adds a Go migration to the history loaded by Load. It is meant
+// to be called from an init function in a file named after the version,
+// e.g. migrations/0005_backfill_calories.go, so that Create skips the
+// number. down may be nil for an irreversible migration. Register panics
+// if the version is zero or already registered.
+func Register(version uint64, name string, up, down GoFunc) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	if version == 0 {
+		panic("migrate: Register version must be greater than zero")
+	}
+	if up == nil {
+		panic(fmt.Sprintf("migrate: Register %d_%s without an up step", version, name))
+	}
+	if _, dup := registry[version]; dup {
+		panic(fmt.Sprintf("migrate: Register called twice for version %d", version))
+	}
+	registry[version] = newGoMigration(version, name, up, down)
+}
+
+func newGoMigration(version uint64, name string, up, down GoFunc) *Migration {
+	return &Migration{
+		Version:  version,
+		Name:     name,
+		UpFunc:   up,
+		DownFunc: down,
+		hasUp:    true,
+		hasDown:  down != nil,
+	}
+}
+
+// registered returns a snapshot of the Go migration registry
+func registered() map[uint64]*Migration {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	snapshot := make(map[uint64]*Migration, len(registry))
+	for v, m := range registry {
+		snapshot[v] = m
+	}
+	return snapshot
+}
@@ -0,0 +1,47 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"hash/fnv"
+)
+
+// Locker serializes migration runs across processes, so that several
+// backend containers starting at once don't migrate concurrently
+type Locker interface {
+	// Lock blocks until the lock on the version table is held or ctx is
+	// done and returns a function that releases it
+	Lock(ctx context.Context, db *sql.DB, table string) (unlock func() error, err error)
+}
+
+// PostgresLock is a Locker backed by a session-level pg_advisory_lock.
+// The key is derived from the version table name, so only migrators
+// sharing a table wait for each other.
+type PostgresLock struct{}
+
+// Lock acquires the advisory lock on a dedicated connection
+func (l PostgresLock) Lock(ctx context.Context, db *sql.DB, table string) (func() error, error) {
+	h := fnv.New64a()
+	h.Write([]byte(table))
+	key := int64(h.Sum64())
+
+	// Advisory locks belong to the session, so lock and unlock must run
+	// on the same connection
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("acquire migration lock: %w", err)
+	}
+	if _, err := conn.ExecContext(ctx, "SELECT pg_advisory_lock($1)", key); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("acquire migration lock: %w", err)
+	}
+
+	return func() error {
+		defer conn.Close()
+		if _, err := conn.ExecContext(context.Background(), "SELECT pg_advisory_unlock($1)", key); err != nil {
+			return fmt.Errorf("release migration lock: %w", err)
+		}
+		return nil
+	}, nil
+}
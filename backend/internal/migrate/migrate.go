@@ -0,0 +1,498 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/config"
+)
+
+// DefaultTable is the table used to record applied migrations
+const DefaultTable = "schema_migrations"
+
+var (
+	// ErrNoChange is returned when there is nothing to apply or roll back
+	ErrNoChange = errors.New("no change")
+	// ErrUnknownVersion is returned by Goto for a version that has no migration
+	ErrUnknownVersion = errors.New("unknown migration version")
+	// ErrIrreversible is returned when rolling back a migration without a down file
+	ErrIrreversible = errors.New("migration has no down step")
+	// ErrChecksumMismatch is returned when an applied migration file was edited
+	ErrChecksumMismatch = errors.New("applied migration was modified")
+	// ErrDirty is returned when a previous run failed half-way. The database
+	// has to be repaired by hand and marked clean with Force.
+	ErrDirty = errors.New("database is dirty")
+	// ErrPending is returned by Check when migrations remain to be applied
+	ErrPending = errors.New("migrations are pending")
+)
+
+// Status describes a single migration and whether it has been applied
+type Status struct {
+	Version   uint64
+	Name      string
+	Applied   bool
+	AppliedAt time.Time
+	// Missing is set for versions recorded in the database that have no
+	// matching migration file
+	Missing bool
+	// Modified is set when the file no longer matches the applied checksum
+	Modified bool
+	// Dirty is set when the last attempt to apply or roll back failed
+	Dirty bool
+}
+
+// record is a row of the version table
+type record struct {
+	appliedAt time.Time
+	checksum  string
+	dirty     bool
+}
+
+// Migrator applies and rolls back migrations against a database/sql handle.
+// It only relies on portable SQL, so any driver that understands $N
+// placeholders (PostgreSQL, SQLite) can be used.
+type Migrator struct {
+	db         *sql.DB
+	migrations []*Migration
+
+	// Table is the name of the version table, DefaultTable if empty
+	Table string
+	// Locker guards Up, Down, Goto and Force against concurrent runs.
+	// Nil means no locking, which is only safe with a single migrator.
+	Locker Locker
+	// Config is passed to Go migrations
+	Config *config.Config
+	// Logf reports progress, log.Printf if nil
+	Logf func(format string, args ...any)
+}
+
+// New creates a migrator for the given, version-ordered migrations
+func New(db *sql.DB, migrations []*Migration) *Migrator {
+	return &Migrator{db: db, migrations: migrations}
+}
+
+// Up applies the next n pending migrations, or all of them if n <= 0
+func (m *Migrator) Up(ctx context.Context, n int) error {
+	return m.locked(ctx, func(applied map[uint64]record) error {
+		var pending []*Migration
+		for _, mig := range m.migrations {
+			if _, ok := applied[mig.Version]; !ok {
+				pending = append(pending, mig)
+			}
+		}
+		if n > 0 && n < len(pending) {
+			pending = pending[:n]
+		}
+		if len(pending) == 0 {
+			return ErrNoChange
+		}
+
+		for _, mig := range pending {
+			if err := m.apply(ctx, mig); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Down rolls back the last n applied migrations, or all of them if n <= 0
+func (m *Migrator) Down(ctx context.Context, n int) error {
+	return m.locked(ctx, func(applied map[uint64]record) error {
+		var done []*Migration
+		for i := len(m.migrations) - 1; i >= 0; i-- {
+			if _, ok := applied[m.migrations[i].Version]; ok {
+				done = append(done, m.migrations[i])
+			}
+		}
+		if n > 0 && n < len(done) {
+			done = done[:n]
+		}
+		if len(done) == 0 {
+			return ErrNoChange
+		}
+
+		for _, mig := range done {
+			if err := m.rollback(ctx, mig); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Goto migrates up or down until exactly the migrations up to and including
+// version are applied. Version 0 rolls everything back.
+func (m *Migrator) Goto(ctx context.Context, version uint64) error {
+	if version != 0 && m.find(version) == nil {
+		return fmt.Errorf("%w: %d", ErrUnknownVersion, version)
+	}
+
+	return m.locked(ctx, func(applied map[uint64]record) error {
+		changed := false
+		for i := len(m.migrations) - 1; i >= 0; i-- {
+			mig := m.migrations[i]
+			if _, ok := applied[mig.Version]; ok && mig.Version > version {
+				if err := m.rollback(ctx, mig); err != nil {
+					return err
+				}
+				changed = true
+			}
+		}
+		for _, mig := range m.migrations {
+			if _, ok := applied[mig.Version]; !ok && mig.Version <= version {
+				if err := m.apply(ctx, mig); err != nil {
+					return err
+				}
+				changed = true
+			}
+		}
+
+		if !changed {
+			return ErrNoChange
+		}
+		return nil
+	})
+}
+
+// Force records version as the current, clean state without running any
+// migration: dirty entries and versions above it are dropped and version
+// itself is stored with the checksum of its current file. Use it after
+// repairing a failed run by hand. Version 0 clears the history.
+func (m *Migrator) Force(ctx context.Context, version uint64) error {
+	var mig *Migration
+	if version != 0 {
+		if mig = m.find(version); mig == nil {
+			return fmt.Errorf("%w: %d", ErrUnknownVersion, version)
+		}
+	}
+
+	unlock, err := m.lock(ctx)
+	if err != nil {
+		return err
+	}
+	defer m.unlock(unlock)
+
+	if err := m.ensureTable(ctx); err != nil {
+		return err
+	}
+
+	m.logf("⚠️  Forcing version %d", version)
+	return m.inTx(ctx, func(tx *sql.Tx) error {
+		_, err := tx.ExecContext(ctx,
+			"DELETE FROM "+m.table()+" WHERE dirty = $1 OR version >= $2", true, int64(version))
+		if err != nil {
+			return fmt.Errorf("force version %d: %w", version, err)
+		}
+		if mig == nil {
+			return nil
+		}
+		_, err = tx.ExecContext(ctx,
+			"INSERT INTO "+m.table()+" (version, name, checksum, dirty, applied_at) VALUES ($1, $2, $3, $4, $5)",
+			int64(mig.Version), mig.Name, mig.Checksum(), false, time.Now().UTC())
+		if err != nil {
+			return fmt.Errorf("force version %d: %w", version, err)
+		}
+		return nil
+	})
+}
+
+// Version returns the highest applied version, or 0 if none
+func (m *Migrator) Version(ctx context.Context) (uint64, error) {
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	var version uint64
+	for v := range applied {
+		if v > version {
+			version = v
+		}
+	}
+	return version, nil
+}
+
+// Check returns an error unless every known migration has been applied
+// and none is dirty. Versions below the highest applied one count too, as
+// Up applies those when branches were merged out of order. Applied versions
+// this binary doesn't know, say from a newer release, are not an error.
+// Unlike Version and Status it only reads the version table, without
+// creating it, so it suits frequent health probes.
+func (m *Migrator) Check(ctx context.Context) error {
+	rows, err := m.db.QueryContext(ctx, "SELECT version, dirty FROM "+m.table())
+	if err != nil {
+		return fmt.Errorf("read %s: %w", m.table(), err)
+	}
+	defer rows.Close()
+
+	applied := make(map[uint64]bool)
+	var dirty []int64
+	for rows.Next() {
+		var (
+			version int64
+			isDirty bool
+		)
+		if err := rows.Scan(&version, &isDirty); err != nil {
+			return fmt.Errorf("read %s: %w", m.table(), err)
+		}
+		applied[uint64(version)] = true
+		if isDirty {
+			dirty = append(dirty, version)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("read %s: %w", m.table(), err)
+	}
+	if len(dirty) > 0 {
+		slices.Sort(dirty)
+		return fmt.Errorf("%w at version %s", ErrDirty, strings.Trim(fmt.Sprint(dirty), "[]"))
+	}
+
+	var pending []string
+	for _, mig := range m.migrations {
+		if !applied[mig.Version] {
+			pending = append(pending, mig.String())
+		}
+	}
+	if len(pending) > 0 {
+		return fmt.Errorf("%w: %s", ErrPending, strings.Join(pending, ", "))
+	}
+	return nil
+}
+
+// Status lists every known migration along with its applied state
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]Status, 0, len(m.migrations))
+	for _, mig := range m.migrations {
+		s := Status{Version: mig.Version, Name: mig.Name}
+		if rec, ok := applied[mig.Version]; ok {
+			s.Applied = true
+			s.AppliedAt = rec.appliedAt
+			s.Dirty = rec.dirty
+			s.Modified = rec.checksum != mig.Checksum()
+			delete(applied, mig.Version)
+		}
+		statuses = append(statuses, s)
+	}
+	for v, rec := range applied {
+		statuses = append(statuses, Status{Version: v, Applied: true, AppliedAt: rec.appliedAt, Dirty: rec.dirty, Missing: true})
+	}
+	sort.Slice(statuses, func(i, j int) bool {
+		return statuses[i].Version < statuses[j].Version
+	})
+
+	return statuses, nil
+}
+
+// locked takes the migration lock, checks that the recorded history is
+// clean and unmodified, and then runs fn with the applied versions
+func (m *Migrator) locked(ctx context.Context, fn func(applied map[uint64]record) error) error {
+	unlock, err := m.lock(ctx)
+	if err != nil {
+		return err
+	}
+	defer m.unlock(unlock)
+
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return err
+	}
+	if err := m.verify(applied); err != nil {
+		return err
+	}
+	return fn(applied)
+}
+
+// verify refuses to continue from a dirty or edited history
+func (m *Migrator) verify(applied map[uint64]record) error {
+	var modified []string
+	for _, mig := range m.migrations {
+		rec, ok := applied[mig.Version]
+		if !ok {
+			continue
+		}
+		if rec.dirty {
+			return fmt.Errorf("%w at version %d, fix it manually and run force", ErrDirty, mig.Version)
+		}
+		if rec.checksum != mig.Checksum() {
+			modified = append(modified, mig.String())
+		}
+	}
+	for v, rec := range applied {
+		if rec.dirty && m.find(v) == nil {
+			return fmt.Errorf("%w at version %d, fix it manually and run force", ErrDirty, v)
+		}
+	}
+
+	if len(modified) > 0 {
+		return fmt.Errorf("%w: %s", ErrChecksumMismatch, strings.Join(modified, ", "))
+	}
+	return nil
+}
+
+// apply runs the up step. The version is recorded as dirty before the
+// transaction starts and marked clean inside it, so a failure leaves a
+// dirty marker behind even for statements a driver can't roll back.
+func (m *Migrator) apply(ctx context.Context, mig *Migration) error {
+	m.logf("⬆️  Applying %s", mig)
+
+	_, err := m.db.ExecContext(ctx,
+		"INSERT INTO "+m.table()+" (version, name, checksum, dirty, applied_at) VALUES ($1, $2, $3, $4, $5)",
+		int64(mig.Version), mig.Name, mig.Checksum(), true, time.Now().UTC())
+	if err != nil {
+		return fmt.Errorf("record %s: %w", mig, err)
+	}
+
+	return m.inTx(ctx, func(tx *sql.Tx) error {
+		if err := m.run(ctx, tx, mig.Up, mig.UpFunc); err != nil {
+			return fmt.Errorf("apply %s: %w", mig, err)
+		}
+		_, err := tx.ExecContext(ctx,
+			"UPDATE "+m.table()+" SET dirty = $1 WHERE version = $2", false, int64(mig.Version))
+		if err != nil {
+			return fmt.Errorf("record %s: %w", mig, err)
+		}
+		return nil
+	})
+}
+
+// rollback runs the down step, marking the version dirty while it runs
+func (m *Migrator) rollback(ctx context.Context, mig *Migration) error {
+	if !mig.Reversible() {
+		return fmt.Errorf("roll back %s: %w", mig, ErrIrreversible)
+	}
+
+	m.logf("⬇️  Rolling back %s", mig)
+
+	_, err := m.db.ExecContext(ctx,
+		"UPDATE "+m.table()+" SET dirty = $1 WHERE version = $2", true, int64(mig.Version))
+	if err != nil {
+		return fmt.Errorf("record %s: %w", mig, err)
+	}
+
+	return m.inTx(ctx, func(tx *sql.Tx) error {
+		if err := m.run(ctx, tx, mig.Down, mig.DownFunc); err != nil {
+			return fmt.Errorf("roll back %s: %w", mig, err)
+		}
+		_, err := tx.ExecContext(ctx,
+			"DELETE FROM "+m.table()+" WHERE version = $1", int64(mig.Version))
+		if err != nil {
+			return fmt.Errorf("unrecord %s: %w", mig, err)
+		}
+		return nil
+	})
+}
+
+// run executes a single step, preferring the Go function when there is one
+func (m *Migrator) run(ctx context.Context, tx *sql.Tx, query string, fn GoFunc) error {
+	if fn != nil {
+		return fn(ctx, tx, m.Config)
+	}
+	_, err := tx.ExecContext(ctx, query)
+	return err
+}
+
+func (m *Migrator) ensureTable(ctx context.Context) error {
+	_, err := m.db.ExecContext(ctx, "CREATE TABLE IF NOT EXISTS "+m.table()+` (
+		version    BIGINT PRIMARY KEY,
+		name       TEXT NOT NULL,
+		checksum   TEXT NOT NULL,
+		dirty      BOOLEAN NOT NULL,
+		applied_at TIMESTAMP NOT NULL
+	)`)
+	if err != nil {
+		return fmt.Errorf("create %s: %w", m.table(), err)
+	}
+	return nil
+}
+
+// applied ensures the version table exists and returns the applied versions
+func (m *Migrator) applied(ctx context.Context) (map[uint64]record, error) {
+	if err := m.ensureTable(ctx); err != nil {
+		return nil, err
+	}
+
+	rows, err := m.db.QueryContext(ctx, "SELECT version, checksum, dirty, applied_at FROM "+m.table())
+	if err != nil {
+		return nil, fmt.Errorf("read %s: %w", m.table(), err)
+	}
+	defer rows.Close()
+
+	applied := make(map[uint64]record)
+	for rows.Next() {
+		var (
+			version int64
+			rec     record
+		)
+		if err := rows.Scan(&version, &rec.checksum, &rec.dirty, &rec.appliedAt); err != nil {
+			return nil, fmt.Errorf("read %s: %w", m.table(), err)
+		}
+		applied[uint64(version)] = rec
+	}
+	return applied, rows.Err()
+}
+
+func (m *Migrator) lock(ctx context.Context) (func() error, error) {
+	if m.Locker == nil {
+		return nil, nil
+	}
+	return m.Locker.Lock(ctx, m.db, m.table())
+}
+
+func (m *Migrator) unlock(unlock func() error) {
+	if unlock == nil {
+		return
+	}
+	if err := unlock(); err != nil {
+		m.logf("⚠️  %v", err)
+	}
+}
+
+func (m *Migrator) inTx(ctx context.Context, fn func(tx *sql.Tx) error) error {
+	tx, err := m.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin transaction: %w", err)
+	}
+	if err := fn(tx); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+func (m *Migrator) find(version uint64) *Migration {
+	for _, mig := range m.migrations {
+		if mig.Version == version {
+			return mig
+		}
+	}
+	return nil
+}
+
+func (m *Migrator) table() string {
+	if m.Table == "" {
+		return DefaultTable
+	}
+	return m.Table
+}
+
+func (m *Migrator) logf(format string, args ...any) {
+	if m.Logf != nil {
+		m.Logf(format, args...)
+		return
+	}
+	log.Printf(format, args...)
+}
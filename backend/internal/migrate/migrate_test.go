@@ -0,0 +1,567 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/config"
+	_ "modernc.org/sqlite"
+)
+
+func testFS() fstest.MapFS {
+	return fstest.MapFS{
+		"0001_create_users.up.sql":      {Data: []byte("CREATE TABLE users (id INTEGER PRIMARY KEY, name TEXT);")},
+		"0001_create_users.down.sql":    {Data: []byte("DROP TABLE users;")},
+		"0002_create_meals.up.sql":      {Data: []byte("CREATE TABLE meals (id INTEGER PRIMARY KEY); CREATE INDEX meals_id ON meals (id);")},
+		"0002_create_meals.down.sql":    {Data: []byte("DROP TABLE meals;")},
+		"0003_add_users_email.up.sql":   {Data: []byte("ALTER TABLE users ADD COLUMN email TEXT;")},
+		"0003_add_users_email.down.sql": {Data: []byte("ALTER TABLE users DROP COLUMN email;")},
+		"README.md":                     {Data: []byte("not a migration")},
+	}
+}
+
+func openTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+	db, err := sql.Open("sqlite", ":memory:")
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	// Each connection to :memory: is a separate database
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func newTestMigrator(t *testing.T, fsys fstest.MapFS) (*Migrator, *sql.DB) {
+	t.Helper()
+	return newTestMigratorWithDB(t, fsys, openTestDB(t))
+}
+
+func newTestMigratorWithDB(t *testing.T, fsys fstest.MapFS, db *sql.DB) (*Migrator, *sql.DB) {
+	t.Helper()
+	migrations, err := Load(fsys)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	m := New(db, migrations)
+	m.Logf = t.Logf
+	return m, db
+}
+
+func tableExists(t *testing.T, db *sql.DB, name string) bool {
+	t.Helper()
+	var count int
+	err := db.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = $1", name).Scan(&count)
+	if err != nil {
+		t.Fatalf("query sqlite_master: %v", err)
+	}
+	return count == 1
+}
+
+func assertVersion(t *testing.T, m *Migrator, want uint64) {
+	t.Helper()
+	got, err := m.Version(context.Background())
+	if err != nil {
+		t.Fatalf("Version: %v", err)
+	}
+	if got != want {
+		t.Errorf("Expected version %d, got %d", want, got)
+	}
+}
+
+func TestLoad(t *testing.T) {
+	migrations, err := Load(testFS())
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+
+	if len(migrations) != 3 {
+		t.Fatalf("Expected 3 migrations, got %d", len(migrations))
+	}
+	for i, want := range []string{"0001_create_users", "0002_create_meals", "0003_add_users_email"} {
+		if got := migrations[i].String(); got != want {
+			t.Errorf("Expected migration %d to be %q, got %q", i, want, got)
+		}
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	tests := map[string]fstest.MapFS{
+		"missing up": {
+			"0001_init.down.sql": {Data: []byte("SELECT 1;")},
+		},
+		"duplicate version": {
+			"0001_init.up.sql":  {Data: []byte("SELECT 1;")},
+			"0001_other.up.sql": {Data: []byte("SELECT 1;")},
+		},
+		"zero version": {
+			"0000_init.up.sql": {Data: []byte("SELECT 1;")},
+		},
+	}
+
+	for name, fsys := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := Load(fsys); err == nil {
+				t.Error("Expected an error, got nil")
+			}
+		})
+	}
+}
+
+func TestUpAndDown(t *testing.T) {
+	ctx := context.Background()
+	m, db := newTestMigrator(t, testFS())
+
+	if err := m.Up(ctx, 1); err != nil {
+		t.Fatalf("Up(1): %v", err)
+	}
+	assertVersion(t, m, 1)
+	if !tableExists(t, db, "users") || tableExists(t, db, "meals") {
+		t.Error("Expected only users table after Up(1)")
+	}
+
+	if err := m.Up(ctx, 0); err != nil {
+		t.Fatalf("Up(0): %v", err)
+	}
+	assertVersion(t, m, 3)
+
+	if err := m.Up(ctx, 0); !errors.Is(err, ErrNoChange) {
+		t.Errorf("Expected ErrNoChange, got %v", err)
+	}
+
+	if err := m.Down(ctx, 2); err != nil {
+		t.Fatalf("Down(2): %v", err)
+	}
+	assertVersion(t, m, 1)
+	if tableExists(t, db, "meals") {
+		t.Error("Expected meals table to be dropped")
+	}
+
+	if err := m.Down(ctx, 0); err != nil {
+		t.Fatalf("Down(0): %v", err)
+	}
+	assertVersion(t, m, 0)
+	if tableExists(t, db, "users") {
+		t.Error("Expected users table to be dropped")
+	}
+}
+
+func TestGoto(t *testing.T) {
+	ctx := context.Background()
+	m, _ := newTestMigrator(t, testFS())
+
+	if err := m.Goto(ctx, 2); err != nil {
+		t.Fatalf("Goto(2): %v", err)
+	}
+	assertVersion(t, m, 2)
+
+	if err := m.Goto(ctx, 3); err != nil {
+		t.Fatalf("Goto(3): %v", err)
+	}
+	assertVersion(t, m, 3)
+
+	if err := m.Goto(ctx, 1); err != nil {
+		t.Fatalf("Goto(1): %v", err)
+	}
+	assertVersion(t, m, 1)
+
+	if err := m.Goto(ctx, 1); !errors.Is(err, ErrNoChange) {
+		t.Errorf("Expected ErrNoChange, got %v", err)
+	}
+
+	if err := m.Goto(ctx, 42); !errors.Is(err, ErrUnknownVersion) {
+		t.Errorf("Expected ErrUnknownVersion, got %v", err)
+	}
+}
+
+func TestFailedMigrationLeavesDirtyState(t *testing.T) {
+	ctx := context.Background()
+	fsys := testFS()
+	fsys["0002_create_meals.up.sql"] = &fstest.MapFile{Data: []byte("CREATE TABLE meals (id INTEGER); SELECT * FROM nope;")}
+	m, db := newTestMigrator(t, fsys)
+
+	if err := m.Up(ctx, 0); err == nil {
+		t.Fatal("Expected Up to fail")
+	}
+	if tableExists(t, db, "meals") {
+		t.Error("Expected partial migration to be rolled back")
+	}
+
+	statuses, err := m.Status(ctx)
+	if err != nil {
+		t.Fatalf("Status: %v", err)
+	}
+	if !statuses[1].Dirty {
+		t.Errorf("Expected version 2 to be dirty, got %+v", statuses[1])
+	}
+
+	if err := m.Up(ctx, 0); !errors.Is(err, ErrDirty) {
+		t.Fatalf("Expected ErrDirty, got %v", err)
+	}
+	if err := m.Down(ctx, 1); !errors.Is(err, ErrDirty) {
+		t.Fatalf("Expected ErrDirty, got %v", err)
+	}
+
+	// The failed migration was rolled back by the transaction, so the
+	// database really is at version 1
+	if err := m.Force(ctx, 1); err != nil {
+		t.Fatalf("Force: %v", err)
+	}
+	assertVersion(t, m, 1)
+
+	fsys["0002_create_meals.up.sql"] = &fstest.MapFile{Data: []byte("CREATE TABLE meals (id INTEGER);")}
+	m, _ = newTestMigratorWithDB(t, fsys, db)
+	if err := m.Up(ctx, 0); err != nil {
+		t.Fatalf("Up after force: %v", err)
+	}
+	assertVersion(t, m, 3)
+}
+
+func TestCheck(t *testing.T) {
+	ctx := context.Background()
+	m, db := newTestMigrator(t, testFS())
+
+	if err := m.Check(ctx); err == nil {
+		t.Error("Expected Check to fail without a version table")
+	}
+	if tableExists(t, db, DefaultTable) {
+		t.Error("Expected Check not to create the version table")
+	}
+
+	if err := m.Up(ctx, 2); err != nil {
+		t.Fatalf("Up: %v", err)
+	}
+	if err := m.Check(ctx); !errors.Is(err, ErrPending) {
+		t.Errorf("Expected ErrPending, got %v", err)
+	}
+
+	if err := m.Up(ctx, 0); err != nil {
+		t.Fatalf("Up: %v", err)
+	}
+	if err := m.Check(ctx); err != nil {
+		t.Errorf("Expected Check to pass, got %v", err)
+	}
+
+	if _, err := db.Exec("UPDATE " + DefaultTable + " SET dirty = TRUE WHERE version = 2"); err != nil {
+		t.Fatalf("mark dirty: %v", err)
+	}
+	if err := m.Check(ctx); !errors.Is(err, ErrDirty) {
+		t.Errorf("Expected ErrDirty, got %v", err)
+	}
+}
+
+func TestCheckReportsGapsBelowTheLatestVersion(t *testing.T) {
+	ctx := context.Background()
+
+	// 0002 comes from a branch merged after 0003 was applied
+	fsys := testFS()
+	delete(fsys, "0002_create_meals.up.sql")
+	delete(fsys, "0002_create_meals.down.sql")
+	early, db := newTestMigrator(t, fsys)
+	if err := early.Up(ctx, 0); err != nil {
+		t.Fatalf("Up: %v", err)
+	}
+
+	m, _ := newTestMigratorWithDB(t, testFS(), db)
+	err := m.Check(ctx)
+	if !errors.Is(err, ErrPending) || !strings.Contains(err.Error(), "0002_create_meals") {
+		t.Errorf("Expected ErrPending naming 0002_create_meals, got %v", err)
+	}
+
+	if err := m.Up(ctx, 0); err != nil {
+		t.Fatalf("Up: %v", err)
+	}
+	if err := m.Check(ctx); err != nil {
+		t.Errorf("Expected Check to pass once the gap is filled, got %v", err)
+	}
+}
+
+func TestChecksumMismatch(t *testing.T) {
+	ctx := context.Background()
+	fsys := testFS()
+	m, db := newTestMigrator(t, fsys)
+
+	if err := m.Up(ctx, 2); err != nil {
+		t.Fatalf("Up: %v", err)
+	}
+
+	fsys["0001_create_users.up.sql"] = &fstest.MapFile{Data: []byte("CREATE TABLE users (id INTEGER PRIMARY KEY, name TEXT, age INTEGER);")}
+	m, _ = newTestMigratorWithDB(t, fsys, db)
+
+	if err := m.Up(ctx, 0); !errors.Is(err, ErrChecksumMismatch) {
+		t.Fatalf("Expected ErrChecksumMismatch, got %v", err)
+	}
+	assertVersion(t, m, 2)
+
+	statuses, err := m.Status(ctx)
+	if err != nil {
+		t.Fatalf("Status: %v", err)
+	}
+	if !statuses[0].Modified || statuses[1].Modified {
+		t.Errorf("Expected only version 1 to be modified, got %+v", statuses[:2])
+	}
+}
+
+type countingLocker struct {
+	locks, unlocks int
+	table          string
+	err            error
+}
+
+func (l *countingLocker) Lock(ctx context.Context, db *sql.DB, table string) (func() error, error) {
+	if l.err != nil {
+		return nil, l.err
+	}
+	l.locks++
+	l.table = table
+	return func() error {
+		l.unlocks++
+		return nil
+	}, nil
+}
+
+func TestLocker(t *testing.T) {
+	ctx := context.Background()
+	m, _ := newTestMigrator(t, testFS())
+	locker := &countingLocker{}
+	m.Locker = locker
+
+	if err := m.Up(ctx, 0); err != nil {
+		t.Fatalf("Up: %v", err)
+	}
+	if err := m.Down(ctx, 1); err != nil {
+		t.Fatalf("Down: %v", err)
+	}
+	if locker.locks != 2 || locker.unlocks != 2 {
+		t.Errorf("Expected 2 locks and unlocks, got %d and %d", locker.locks, locker.unlocks)
+	}
+	if locker.table != DefaultTable {
+		t.Errorf("Expected the lock on %s, got %q", DefaultTable, locker.table)
+	}
+
+	custom, _ := newTestMigrator(t, testFS())
+	custom.Table = "custom_migrations"
+	custom.Locker = locker
+	if err := custom.Up(ctx, 0); err != nil {
+		t.Fatalf("Up with a custom table: %v", err)
+	}
+	if locker.table != "custom_migrations" {
+		t.Errorf("Expected the lock on the custom table, got %q", locker.table)
+	}
+
+	locker.err = errors.New("lock timeout")
+	if err := m.Up(ctx, 0); !errors.Is(err, locker.err) {
+		t.Errorf("Expected lock error, got %v", err)
+	}
+	assertVersion(t, m, 2)
+}
+
+func TestIrreversible(t *testing.T) {
+	ctx := context.Background()
+	fsys := testFS()
+	delete(fsys, "0003_add_users_email.down.sql")
+	m, _ := newTestMigrator(t, fsys)
+
+	if err := m.Up(ctx, 0); err != nil {
+		t.Fatalf("Up: %v", err)
+	}
+	if err := m.Down(ctx, 1); !errors.Is(err, ErrIrreversible) {
+		t.Errorf("Expected ErrIrreversible, got %v", err)
+	}
+	assertVersion(t, m, 3)
+}
+
+func TestStatus(t *testing.T) {
+	ctx := context.Background()
+	m, db := newTestMigrator(t, testFS())
+
+	if err := m.Up(ctx, 2); err != nil {
+		t.Fatalf("Up: %v", err)
+	}
+	if _, err := db.Exec("INSERT INTO schema_migrations (version, name, checksum, dirty, applied_at) VALUES (99, 'gone', '', FALSE, CURRENT_TIMESTAMP)"); err != nil {
+		t.Fatalf("insert orphan version: %v", err)
+	}
+
+	statuses, err := m.Status(ctx)
+	if err != nil {
+		t.Fatalf("Status: %v", err)
+	}
+	if len(statuses) != 4 {
+		t.Fatalf("Expected 4 statuses, got %d", len(statuses))
+	}
+
+	wantApplied := []bool{true, true, false, true}
+	for i, s := range statuses {
+		if s.Applied != wantApplied[i] {
+			t.Errorf("Expected version %d applied=%v, got %v", s.Version, wantApplied[i], s.Applied)
+		}
+	}
+	if !statuses[3].Missing || statuses[3].Version != 99 {
+		t.Errorf("Expected version 99 to be reported as missing, got %+v", statuses[3])
+	}
+}
+
+func TestCreate(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	up, down, err := Create(dir, "Create Users", now)
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if filepath.Base(up) != "0001_create_users.up.sql" || filepath.Base(down) != "0001_create_users.down.sql" {
+		t.Errorf("Unexpected file names %s, %s", up, down)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, "0007_manual.up.sql"), nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	up, _, err = Create(dir, "add-meals", now)
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if filepath.Base(up) != "0008_add_meals.up.sql" {
+		t.Errorf("Expected next version after 0007, got %s", up)
+	}
+
+	migrations, err := Load(os.DirFS(dir))
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if len(migrations) != 3 {
+		t.Errorf("Expected 3 migrations, got %d", len(migrations))
+	}
+
+	if _, _, err := Create(dir, "!!!", now); err == nil {
+		t.Error("Expected an error for an empty name")
+	}
+}
+
+func TestCreateRefusesExistingFile(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "0001_init.down.sql"), nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	// A stray down file with version 1 makes the next version 2
+	up, _, err := Create(dir, "init", time.Now())
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if filepath.Base(up) != "0002_init.up.sql" {
+		t.Errorf("Expected 0002_init.up.sql, got %s", up)
+	}
+
+	if err := writeNew(up, ""); !errors.Is(err, ErrVersionExists) {
+		t.Errorf("Expected ErrVersionExists, got %v", err)
+	}
+}
+
+func TestGoMigrations(t *testing.T) {
+	ctx := context.Background()
+	cfg := &config.Config{Env: "test"}
+
+	var seenEnv string
+	backfill := newGoMigration(2, "backfill_names", func(ctx context.Context, tx *sql.Tx, cfg *config.Config) error {
+		seenEnv = cfg.Env
+		_, err := tx.ExecContext(ctx, "INSERT INTO users (name) VALUES ($1)", "backfilled")
+		return err
+	}, func(ctx context.Context, tx *sql.Tx, cfg *config.Config) error {
+		_, err := tx.ExecContext(ctx, "DELETE FROM users WHERE name = $1", "backfilled")
+		return err
+	})
+
+	fsys := testFS()
+	fsys["0004_create_meals.up.sql"] = fsys["0002_create_meals.up.sql"]
+	fsys["0004_create_meals.down.sql"] = fsys["0002_create_meals.down.sql"]
+	delete(fsys, "0002_create_meals.up.sql")
+	delete(fsys, "0002_create_meals.down.sql")
+
+	migrations, err := load(fsys, map[uint64]*Migration{2: backfill})
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	for i, want := range []uint64{1, 2, 3, 4} {
+		if migrations[i].Version != want {
+			t.Fatalf("Expected version %d at position %d, got %d", want, i, migrations[i].Version)
+		}
+	}
+
+	db := openTestDB(t)
+	m := New(db, migrations)
+	m.Config = cfg
+	m.Logf = t.Logf
+
+	if err := m.Goto(ctx, 2); err != nil {
+		t.Fatalf("Goto(2): %v", err)
+	}
+	if seenEnv != "test" {
+		t.Errorf("Expected Go migration to receive the config, got env %q", seenEnv)
+	}
+
+	var count int
+	if err := db.QueryRow("SELECT COUNT(*) FROM users").Scan(&count); err != nil {
+		t.Fatal(err)
+	}
+	if count != 1 {
+		t.Errorf("Expected 1 backfilled user, got %d", count)
+	}
+
+	if err := m.Up(ctx, 0); err != nil {
+		t.Fatalf("Up: %v", err)
+	}
+	if err := m.Down(ctx, 3); err != nil {
+		t.Fatalf("Down(3): %v", err)
+	}
+	if err := db.QueryRow("SELECT COUNT(*) FROM users").Scan(&count); err != nil {
+		t.Fatal(err)
+	}
+	if count != 0 {
+		t.Errorf("Expected Go down step to remove the backfill, got %d users", count)
+	}
+}
+
+func TestGoMigrationRunsInTransaction(t *testing.T) {
+	ctx := context.Background()
+	failing := newGoMigration(2, "half_done", func(ctx context.Context, tx *sql.Tx, cfg *config.Config) error {
+		if _, err := tx.ExecContext(ctx, "INSERT INTO users (name) VALUES ($1)", "partial"); err != nil {
+			return err
+		}
+		return errors.New("backfill failed")
+	}, nil)
+
+	fsys := fstest.MapFS{"0001_create_users.up.sql": testFS()["0001_create_users.up.sql"]}
+	migrations, err := load(fsys, map[uint64]*Migration{2: failing})
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	db := openTestDB(t)
+	m := New(db, migrations)
+	m.Logf = t.Logf
+
+	if err := m.Up(ctx, 0); err == nil {
+		t.Fatal("Expected Up to fail")
+	}
+
+	var count int
+	if err := db.QueryRow("SELECT COUNT(*) FROM users").Scan(&count); err != nil {
+		t.Fatal(err)
+	}
+	if count != 0 {
+		t.Errorf("Expected the Go migration's writes to be rolled back, got %d users", count)
+	}
+}
+
+func TestLoadRejectsSQLAndGoClash(t *testing.T) {
+	clash := newGoMigration(1, "also_users", func(context.Context, *sql.Tx, *config.Config) error { return nil }, nil)
+	if _, err := load(testFS(), map[uint64]*Migration{1: clash}); err == nil {
+		t.Error("Expected an error for a version used by SQL and Go migrations")
+	}
+}
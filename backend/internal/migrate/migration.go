@@ -0,0 +1,126 @@
+package migrate
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/fs"
+	"regexp"
+	"sort"
+	"strconv"
+)
+
+// fileNamePattern matches migration files such as 0001_create_users.up.sql
+var fileNamePattern = regexp.MustCompile(`^(\d+)_([A-Za-z0-9_]+)\.(up|down)\.sql$`)
+
+// Migration is a single versioned schema change, either a pair of SQL
+// files or a pair of Go functions registered with Register
+type Migration struct {
+	Version uint64
+	Name    string
+	Up      string
+	Down    string
+
+	UpFunc   GoFunc
+	DownFunc GoFunc
+
+	hasUp   bool
+	hasDown bool
+}
+
+// Reversible reports whether the migration has a down step
+func (m *Migration) Reversible() bool {
+	return m.hasDown
+}
+
+// Checksum returns the SHA-256 of the up step, which is what gets recorded
+// when the migration is applied. Go code can't be hashed, so Go migrations
+// are identified by their name alone.
+func (m *Migration) Checksum() string {
+	body := m.Up
+	if m.UpFunc != nil {
+		body = "go:" + m.Name
+	}
+	sum := sha256.Sum256([]byte(body))
+	return hex.EncodeToString(sum[:])
+}
+
+// String returns the migration in NNNN_name form
+func (m *Migration) String() string {
+	return fmt.Sprintf("%04d_%s", m.Version, m.Name)
+}
+
+// Load reads all migration files from the root of fsys, merges in the Go
+// migrations added with Register and returns them ordered by version.
+// Files that don't follow the NNNN_name.(up|down).sql naming scheme are
+// ignored.
+func Load(fsys fs.FS) ([]*Migration, error) {
+	return load(fsys, registered())
+}
+
+func load(fsys fs.FS, goMigrations map[uint64]*Migration) ([]*Migration, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, fmt.Errorf("read migrations directory: %w", err)
+	}
+
+	byVersion := make(map[uint64]*Migration)
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		match := fileNamePattern.FindStringSubmatch(entry.Name())
+		if match == nil {
+			continue
+		}
+
+		version, err := strconv.ParseUint(match[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("migration %s: invalid version: %w", entry.Name(), err)
+		}
+		if version == 0 {
+			return nil, fmt.Errorf("migration %s: version must be greater than zero", entry.Name())
+		}
+
+		body, err := fs.ReadFile(fsys, entry.Name())
+		if err != nil {
+			return nil, fmt.Errorf("read migration %s: %w", entry.Name(), err)
+		}
+
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = m
+		} else if m.Name != match[2] {
+			return nil, fmt.Errorf("migration version %d is used by both %q and %q", version, m.Name, match[2])
+		}
+
+		if match[3] == "up" {
+			m.Up = string(body)
+			m.hasUp = true
+		} else {
+			m.Down = string(body)
+			m.hasDown = true
+		}
+	}
+
+	for version, m := range goMigrations {
+		if sqlMigration, ok := byVersion[version]; ok {
+			return nil, fmt.Errorf("migration version %d is used by both %q (SQL) and %q (Go)", version, sqlMigration.Name, m.Name)
+		}
+		byVersion[version] = m
+	}
+
+	migrations := make([]*Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if !m.hasUp {
+			return nil, fmt.Errorf("migration %s has no up file", m)
+		}
+		migrations = append(migrations, m)
+	}
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+
+	return migrations, nil
+}
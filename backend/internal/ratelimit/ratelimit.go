@@ -0,0 +1,78 @@
+// Package ratelimit implements token-bucket rate limiting with pluggable
+// storage for the bucket state
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"time"
+)
+
+// Limit describes a token bucket: it holds up to Burst tokens and refills
+// at Rate tokens per second. Each request takes one token.
+type Limit struct {
+	Rate  float64
+	Burst int
+}
+
+// Unlimited reports whether the limit lets every request through
+func (l Limit) Unlimited() bool {
+	return l.Rate <= 0 || l.Burst <= 0
+}
+
+// Result is the outcome of taking a token
+type Result struct {
+	Allowed bool
+	// Limit is the bucket size
+	Limit int
+	// Remaining is the number of whole tokens left after this request
+	Remaining int
+	// RetryAfter is how long to wait until a token is available; zero
+	// when the request was allowed
+	RetryAfter time.Duration
+	// Reset is how long until the bucket is full again
+	Reset time.Duration
+}
+
+// Store keeps bucket state. Implementations must make Take atomic so
+// several server instances can share a store.
+type Store interface {
+	// Take removes a token from the bucket for key, if one is available
+	Take(ctx context.Context, key string, limit Limit, now time.Time) (Result, error)
+}
+
+// bucket is the state of a single token bucket
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// take refills b for the time elapsed since it was last used and removes
+// a token if possible. The Redis script implements the same steps.
+func (b *bucket) take(limit Limit, now time.Time) Result {
+	if elapsed := now.Sub(b.last).Seconds(); elapsed > 0 {
+		b.tokens = math.Min(float64(limit.Burst), b.tokens+elapsed*limit.Rate)
+		b.last = now
+	}
+
+	allowed := b.tokens >= 1
+	if allowed {
+		b.tokens--
+	}
+	return newResult(limit, allowed, b.tokens)
+}
+
+// newResult describes a bucket left with tokens after a request. It is
+// shared by the stores so they report the same headers.
+func newResult(limit Limit, allowed bool, tokens float64) Result {
+	result := Result{Allowed: allowed, Limit: limit.Burst, Remaining: int(tokens)}
+	if !allowed {
+		result.RetryAfter = seconds((1 - tokens) / limit.Rate)
+	}
+	result.Reset = seconds((float64(limit.Burst) - tokens) / limit.Rate)
+	return result
+}
+
+func seconds(s float64) time.Duration {
+	return time.Duration(math.Ceil(s * float64(time.Second)))
+}
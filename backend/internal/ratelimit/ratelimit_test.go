@@ -0,0 +1,113 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+// stores returns every Store implementation so they are held to the same
+// behaviour
+func stores(t *testing.T) map[string]Store {
+	t.Helper()
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	t.Cleanup(func() { client.Close() })
+
+	return map[string]Store{
+		"memory": NewMemoryStore(),
+		"redis":  NewRedisStore(client),
+	}
+}
+
+func TestTokenBucket(t *testing.T) {
+	ctx := context.Background()
+	limit := Limit{Rate: 2, Burst: 3}
+	start := time.Unix(1_700_000_000, 0)
+
+	steps := []struct {
+		name          string
+		at            time.Duration
+		wantAllowed   bool
+		wantRemaining int
+		wantRetry     time.Duration
+	}{
+		{"first request", 0, true, 2, 0},
+		{"second request", 0, true, 1, 0},
+		{"last token of the burst", 0, true, 0, 0},
+		{"bucket empty", 0, false, 0, 500 * time.Millisecond},
+		{"half refilled", 250 * time.Millisecond, false, 0, 250 * time.Millisecond},
+		{"one token refilled", 500 * time.Millisecond, true, 0, 0},
+		{"refilled to burst, not beyond", 10 * time.Second, true, 2, 0},
+	}
+
+	for name, store := range stores(t) {
+		t.Run(name, func(t *testing.T) {
+			for _, step := range steps {
+				result, err := store.Take(ctx, "client", limit, start.Add(step.at))
+				if err != nil {
+					t.Fatalf("%s: Take failed: %v", step.name, err)
+				}
+				if result.Allowed != step.wantAllowed || result.Remaining != step.wantRemaining {
+					t.Errorf("%s: expected allowed=%v remaining=%d, got allowed=%v remaining=%d",
+						step.name, step.wantAllowed, step.wantRemaining, result.Allowed, result.Remaining)
+				}
+				if result.RetryAfter != step.wantRetry {
+					t.Errorf("%s: expected retry after %v, got %v", step.name, step.wantRetry, result.RetryAfter)
+				}
+				if result.Limit != limit.Burst {
+					t.Errorf("%s: expected limit %d, got %d", step.name, limit.Burst, result.Limit)
+				}
+			}
+		})
+	}
+}
+
+func TestTokenBucketKeysAreIndependent(t *testing.T) {
+	ctx := context.Background()
+	limit := Limit{Rate: 1, Burst: 1}
+	now := time.Now()
+
+	for name, store := range stores(t) {
+		t.Run(name, func(t *testing.T) {
+			if r, _ := store.Take(ctx, "a", limit, now); !r.Allowed {
+				t.Fatal("Expected the first request for a to be allowed")
+			}
+			if r, _ := store.Take(ctx, "a", limit, now); r.Allowed {
+				t.Error("Expected the second request for a to be limited")
+			}
+			if r, _ := store.Take(ctx, "b", limit, now); !r.Allowed {
+				t.Error("Expected b to have its own bucket")
+			}
+		})
+	}
+}
+
+func TestMemoryStoreSweepsFullBuckets(t *testing.T) {
+	store := NewMemoryStore()
+	limit := Limit{Rate: 1, Burst: 2}
+	now := time.Now()
+
+	store.Take(context.Background(), "idle", limit, now)
+	store.Take(context.Background(), "busy", limit, now.Add(sweepInterval))
+	if store.Len() != 1 {
+		t.Errorf("Expected the refilled bucket to be dropped, got %d buckets", store.Len())
+	}
+}
+
+func TestRedisStoreExpiresKeys(t *testing.T) {
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	defer client.Close()
+
+	store := NewRedisStore(client)
+	if _, err := store.Take(context.Background(), "client", Limit{Rate: 1, Burst: 5}, time.Now()); err != nil {
+		t.Fatalf("Take failed: %v", err)
+	}
+	if ttl := server.TTL("ratelimit:client"); ttl <= 0 || ttl > 3*time.Second {
+		t.Errorf("Expected the key to expire once the bucket refills, got TTL %v", ttl)
+	}
+}
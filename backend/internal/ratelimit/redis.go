@@ -0,0 +1,76 @@
+package ratelimit
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// takeScript refills and takes from a bucket stored as a hash with the
+// fields tokens and last (Unix microseconds). It runs atomically on the
+// server, so instances sharing Redis share the limit. The key expires
+// once the bucket would be full again.
+var takeScript = redis.NewScript(`
+local rate = tonumber(ARGV[1])
+local burst = tonumber(ARGV[2])
+local now = tonumber(ARGV[3])
+
+local state = redis.call("HMGET", KEYS[1], "tokens", "last")
+local tokens = tonumber(state[1])
+local last = tonumber(state[2])
+if tokens == nil then
+	tokens = burst
+	last = now
+end
+
+local elapsed = (now - last) / 1e6
+if elapsed > 0 then
+	tokens = math.min(burst, tokens + elapsed * rate)
+	last = now
+end
+
+local allowed = 0
+if tokens >= 1 then
+	tokens = tokens - 1
+	allowed = 1
+end
+
+redis.call("HSET", KEYS[1], "tokens", tostring(tokens), "last", tostring(last))
+redis.call("PEXPIRE", KEYS[1], math.ceil((burst - tokens) / rate * 1000) + 1000)
+return {allowed, tostring(tokens)}
+`)
+
+// RedisStore keeps buckets in Redis, or anything speaking its protocol
+type RedisStore struct {
+	client redis.Scripter
+	// Prefix is prepended to every key
+	Prefix string
+}
+
+// NewRedisStore wraps a Redis client
+func NewRedisStore(client redis.Scripter) *RedisStore {
+	return &RedisStore{client: client, Prefix: "ratelimit:"}
+}
+
+// Take implements Store
+func (s *RedisStore) Take(ctx context.Context, key string, limit Limit, now time.Time) (Result, error) {
+	args := []any{
+		strconv.FormatFloat(limit.Rate, 'f', -1, 64),
+		limit.Burst,
+		now.UnixMicro(),
+	}
+	reply, err := takeScript.Run(ctx, s.client, []string{s.Prefix + key}, args...).Slice()
+	if err != nil {
+		return Result{}, err
+	}
+
+	allowed, _ := reply[0].(int64)
+	raw, _ := reply[1].(string)
+	tokens, err := strconv.ParseFloat(raw, 64)
+	if err != nil {
+		return Result{}, err
+	}
+	return newResult(limit, allowed == 1, tokens), nil
+}
@@ -0,0 +1,342 @@
+package storage
+
+import (
+	"cmp"
+	"context"
+	"maps"
+	"slices"
+	"sync"
+	"time"
+)
+
+// Memory keeps every repository in process memory, for tests and
+// development. Units of work run one at a time and restore the previous
+// state when they fail.
+type Memory struct {
+	// txMu is held for a whole unit of work, or for a single call made
+	// outside one, so a rollback never discards another caller's writes
+	txMu sync.Mutex
+	// mu guards the maps against calls made concurrently within a unit
+	// of work
+	mu sync.Mutex
+	memoryState
+}
+
+// memoryState is everything a rollback restores
+type memoryState struct {
+	users      map[string]User
+	emails     map[string]string
+	activities map[string]Activity
+	meals      map[string]Meal
+	messages   map[string]Message
+}
+
+// memoryTx marks a context as running within a unit of work of a Memory
+type memoryTx struct{}
+
+// NewMemory returns an empty in-memory store
+func NewMemory() *Memory {
+	return &Memory{memoryState: memoryState{
+		users:      make(map[string]User),
+		emails:     make(map[string]string),
+		activities: make(map[string]Activity),
+		meals:      make(map[string]Meal),
+		messages:   make(map[string]Message),
+	}}
+}
+
+// Users implements Store
+func (m *Memory) Users() UserRepository { return memoryUsers{m} }
+
+// Activities implements Store
+func (m *Memory) Activities() ActivityRepository { return memoryActivities{m} }
+
+// Meals implements Store
+func (m *Memory) Meals() MealRepository { return memoryMeals{m} }
+
+// Messages implements Store
+func (m *Memory) Messages() MessageRepository { return memoryMessages{m} }
+
+// WithinTx implements Store
+func (m *Memory) WithinTx(ctx context.Context, fn func(ctx context.Context) error) (err error) {
+	if ctx.Value(memoryTx{}) == m {
+		return fn(ctx)
+	}
+
+	m.txMu.Lock()
+	defer m.txMu.Unlock()
+
+	m.mu.Lock()
+	saved := m.clone()
+	m.mu.Unlock()
+
+	defer func() {
+		if p := recover(); p != nil {
+			m.restore(saved)
+			panic(p)
+		}
+		if err != nil {
+			m.restore(saved)
+		}
+	}()
+	return fn(context.WithValue(ctx, memoryTx{}, m))
+}
+
+// lock locks the maps for a repository call, first waiting for any unit of
+// work the call isn't part of
+func (m *Memory) lock(ctx context.Context) func() {
+	if ctx.Value(memoryTx{}) == m {
+		m.mu.Lock()
+		return m.mu.Unlock
+	}
+	m.txMu.Lock()
+	m.mu.Lock()
+	return func() {
+		m.mu.Unlock()
+		m.txMu.Unlock()
+	}
+}
+
+// clone copies the maps; the records are values, so copying the maps is
+// enough
+func (m *Memory) clone() memoryState {
+	return memoryState{
+		users:      maps.Clone(m.users),
+		emails:     maps.Clone(m.emails),
+		activities: maps.Clone(m.activities),
+		meals:      maps.Clone(m.meals),
+		messages:   maps.Clone(m.messages),
+	}
+}
+
+// restore rolls the maps back to saved
+func (m *Memory) restore(saved memoryState) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.memoryState = saved
+}
+
+// memoryUsers is the UserRepository of a Memory
+type memoryUsers struct{ m *Memory }
+
+// Create implements UserRepository
+func (r memoryUsers) Create(ctx context.Context, user *User) error {
+	defer r.m.lock(ctx)()
+
+	if _, ok := r.m.users[user.ID]; ok && user.ID != "" {
+		return ErrConflict
+	}
+	if _, ok := r.m.emails[user.Email]; ok {
+		return ErrConflict
+	}
+	user.ID = newID(user.ID)
+	user.CreatedAt = timestamp(user.CreatedAt)
+	stored := *user
+	stored.Roles = slices.Clone(user.Roles)
+	r.m.users[user.ID] = stored
+	r.m.emails[user.Email] = user.ID
+	return nil
+}
+
+// ByID implements UserRepository
+func (r memoryUsers) ByID(ctx context.Context, id string) (*User, error) {
+	defer r.m.lock(ctx)()
+
+	user, ok := r.m.users[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	user.Roles = slices.Clone(user.Roles)
+	return &user, nil
+}
+
+// ByEmail implements UserRepository
+func (r memoryUsers) ByEmail(ctx context.Context, email string) (*User, error) {
+	defer r.m.lock(ctx)()
+
+	user, ok := r.m.users[r.m.emails[email]]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	user.Roles = slices.Clone(user.Roles)
+	return &user, nil
+}
+
+// memoryActivities is the ActivityRepository of a Memory
+type memoryActivities struct{ m *Memory }
+
+// Create implements ActivityRepository
+func (r memoryActivities) Create(ctx context.Context, activity *Activity) error {
+	defer r.m.lock(ctx)()
+
+	if _, ok := r.m.activities[activity.ID]; ok && activity.ID != "" {
+		return ErrConflict
+	}
+	activity.ID = newID(activity.ID)
+	activity.Duration = activity.Duration.Truncate(time.Second)
+	activity.StartedAt = timestamp(activity.StartedAt)
+	activity.CreatedAt = timestamp(activity.CreatedAt)
+	r.m.activities[activity.ID] = *activity
+	return nil
+}
+
+// ByID implements ActivityRepository
+func (r memoryActivities) ByID(ctx context.Context, userID, id string) (*Activity, error) {
+	defer r.m.lock(ctx)()
+
+	activity, ok := r.m.activities[id]
+	if !ok || activity.UserID != userID {
+		return nil, ErrNotFound
+	}
+	return &activity, nil
+}
+
+// List implements ActivityRepository
+func (r memoryActivities) List(ctx context.Context, userID string, opts ListOptions) ([]Activity, error) {
+	defer r.m.lock(ctx)()
+
+	return list(r.m.activities, userID, opts, func(a Activity) (string, string, time.Time) {
+		return a.UserID, a.ID, a.StartedAt
+	}), nil
+}
+
+// Delete implements ActivityRepository
+func (r memoryActivities) Delete(ctx context.Context, userID, id string) error {
+	defer r.m.lock(ctx)()
+
+	if activity, ok := r.m.activities[id]; !ok || activity.UserID != userID {
+		return ErrNotFound
+	}
+	delete(r.m.activities, id)
+	return nil
+}
+
+// memoryMeals is the MealRepository of a Memory
+type memoryMeals struct{ m *Memory }
+
+// Create implements MealRepository
+func (r memoryMeals) Create(ctx context.Context, meal *Meal) error {
+	defer r.m.lock(ctx)()
+
+	if _, ok := r.m.meals[meal.ID]; ok && meal.ID != "" {
+		return ErrConflict
+	}
+	meal.ID = newID(meal.ID)
+	meal.EatenAt = timestamp(meal.EatenAt)
+	meal.CreatedAt = timestamp(meal.CreatedAt)
+	r.m.meals[meal.ID] = *meal
+	return nil
+}
+
+// ByID implements MealRepository
+func (r memoryMeals) ByID(ctx context.Context, userID, id string) (*Meal, error) {
+	defer r.m.lock(ctx)()
+
+	meal, ok := r.m.meals[id]
+	if !ok || meal.UserID != userID {
+		return nil, ErrNotFound
+	}
+	return &meal, nil
+}
+
+// List implements MealRepository
+func (r memoryMeals) List(ctx context.Context, userID string, opts ListOptions) ([]Meal, error) {
+	defer r.m.lock(ctx)()
+
+	return list(r.m.meals, userID, opts, func(m Meal) (string, string, time.Time) {
+		return m.UserID, m.ID, m.EatenAt
+	}), nil
+}
+
+// Delete implements MealRepository
+func (r memoryMeals) Delete(ctx context.Context, userID, id string) error {
+	defer r.m.lock(ctx)()
+
+	if meal, ok := r.m.meals[id]; !ok || meal.UserID != userID {
+		return ErrNotFound
+	}
+	delete(r.m.meals, id)
+	return nil
+}
+
+// memoryMessages is the MessageRepository of a Memory
+type memoryMessages struct{ m *Memory }
+
+// Create implements MessageRepository
+func (r memoryMessages) Create(ctx context.Context, message *Message) error {
+	defer r.m.lock(ctx)()
+
+	if _, ok := r.m.messages[message.ID]; ok && message.ID != "" {
+		return ErrConflict
+	}
+	message.ID = newID(message.ID)
+	message.CreatedAt = timestamp(message.CreatedAt)
+	if message.ReadAt != nil {
+		readAt := timestamp(*message.ReadAt)
+		message.ReadAt = &readAt
+	}
+	r.m.messages[message.ID] = *message
+	return nil
+}
+
+// List implements MessageRepository
+func (r memoryMessages) List(ctx context.Context, userID string, opts ListOptions) ([]Message, error) {
+	defer r.m.lock(ctx)()
+
+	var out []Message
+	for _, message := range r.m.messages {
+		if (message.SenderID == userID || message.RecipientID == userID) && opts.includes(message.CreatedAt) {
+			if message.ReadAt != nil {
+				readAt := *message.ReadAt
+				message.ReadAt = &readAt
+			}
+			out = append(out, message)
+		}
+	}
+	return newestFirst(out, opts, func(m Message) (string, time.Time) { return m.ID, m.CreatedAt }), nil
+}
+
+// MarkRead implements MessageRepository
+func (r memoryMessages) MarkRead(ctx context.Context, recipientID, id string, at time.Time) error {
+	defer r.m.lock(ctx)()
+
+	message, ok := r.m.messages[id]
+	if !ok || message.RecipientID != recipientID {
+		return ErrNotFound
+	}
+	if message.ReadAt == nil {
+		readAt := timestamp(at)
+		message.ReadAt = &readAt
+		r.m.messages[id] = message
+	}
+	return nil
+}
+
+// list returns the records of a user within opts, newest first. key gives
+// the owner of a record and the time it is listed by.
+func list[T any](records map[string]T, userID string, opts ListOptions, key func(T) (owner, id string, at time.Time)) []T {
+	var out []T
+	for _, record := range records {
+		if owner, _, at := key(record); owner == userID && opts.includes(at) {
+			out = append(out, record)
+		}
+	}
+	return newestFirst(out, opts, func(record T) (string, time.Time) {
+		_, id, at := key(record)
+		return id, at
+	})
+}
+
+// newestFirst sorts records by time, newest first and then by ID like the
+// Postgres queries, and cuts them to the page size
+func newestFirst[T any](records []T, opts ListOptions, key func(T) (id string, at time.Time)) []T {
+	slices.SortFunc(records, func(a, b T) int {
+		aID, aAt := key(a)
+		bID, bAt := key(b)
+		return cmp.Or(bAt.Compare(aAt), cmp.Compare(bID, aID))
+	})
+	if len(records) > opts.limit() {
+		records = records[:opts.limit()]
+	}
+	return records
+}
@@ -0,0 +1,7 @@
+package storage
+
+import "testing"
+
+func TestMemory(t *testing.T) {
+	testStore(t, func(t *testing.T) Store { return NewMemory() })
+}
@@ -0,0 +1,447 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	_ "github.com/lib/pq"
+	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/tracing"
+	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// PoolConfig sizes the database connection pool. Zero values keep the
+// database/sql defaults.
+type PoolConfig struct {
+	MaxOpenConns    int
+	MaxIdleConns    int
+	ConnMaxLifetime time.Duration
+	ConnMaxIdleTime time.Duration
+}
+
+// Open returns a Postgres connection pool for url. Connections are opened
+// on first use, so Open succeeds while the database is down.
+func Open(url string, pool PoolConfig) (*sql.DB, error) {
+	db, err := sql.Open("postgres", url)
+	if err != nil {
+		return nil, err
+	}
+	db.SetMaxOpenConns(pool.MaxOpenConns)
+	if pool.MaxIdleConns != 0 {
+		db.SetMaxIdleConns(pool.MaxIdleConns)
+	}
+	db.SetConnMaxLifetime(pool.ConnMaxLifetime)
+	db.SetConnMaxIdleTime(pool.ConnMaxIdleTime)
+	return db, nil
+}
+
+// Postgres keeps every repository in the tables created by the migrations
+type Postgres struct {
+	db *sql.DB
+}
+
+// NewPostgres returns a store using db
+func NewPostgres(db *sql.DB) *Postgres {
+	return &Postgres{db: db}
+}
+
+// querier runs queries on the pool or within a transaction
+type querier interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// postgresTx carries the transaction of a unit of work in a context
+type postgresTx struct{}
+
+// txFor is the transaction a unit of work runs in, and the pool it is on
+type txFor struct {
+	db *sql.DB
+	tx *sql.Tx
+}
+
+// Users implements Store
+func (s *Postgres) Users() UserRepository { return postgresUsers{s} }
+
+// Activities implements Store
+func (s *Postgres) Activities() ActivityRepository { return postgresActivities{s} }
+
+// Meals implements Store
+func (s *Postgres) Meals() MealRepository { return postgresMeals{s} }
+
+// Messages implements Store
+func (s *Postgres) Messages() MessageRepository { return postgresMessages{s} }
+
+// WithinTx implements Store
+func (s *Postgres) WithinTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	if s.tx(ctx) != nil {
+		return fn(ctx)
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin transaction: %w", err)
+	}
+	defer func() {
+		if p := recover(); p != nil {
+			_ = tx.Rollback()
+			panic(p)
+		}
+	}()
+
+	if err := fn(context.WithValue(ctx, postgresTx{}, txFor{db: s.db, tx: tx})); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit transaction: %w", err)
+	}
+	return nil
+}
+
+// tx returns the transaction of the unit of work ctx is in, if it is one
+// of this store's
+func (s *Postgres) tx(ctx context.Context) *sql.Tx {
+	if t, ok := ctx.Value(postgresTx{}).(txFor); ok && t.db == s.db {
+		return t.tx
+	}
+	return nil
+}
+
+// conn returns where queries made with ctx run
+func (s *Postgres) conn(ctx context.Context) querier {
+	if tx := s.tx(ctx); tx != nil {
+		return tx
+	}
+	return s.db
+}
+
+// startSpan starts the client span of a query
+func startSpan(ctx context.Context, operation, table string) (context.Context, trace.Span) {
+	return tracing.StartClient(ctx, operation+" "+table,
+		semconv.DBSystemNamePostgreSQL,
+		semconv.DBOperationName(operation),
+		semconv.DBCollectionName(table),
+	)
+}
+
+// endSpan ends the span of a query. ErrNotFound and ErrConflict are
+// answers rather than failures, so they aren't recorded.
+func endSpan(span trace.Span, err error) {
+	if errors.Is(err, ErrNotFound) || errors.Is(err, ErrConflict) {
+		err = nil
+	}
+	tracing.End(span, err)
+}
+
+// insert runs an INSERT ... ON CONFLICT DO NOTHING, returning ErrConflict
+// when it inserted nothing
+func (s *Postgres) insert(ctx context.Context, table, query string, args ...any) (err error) {
+	ctx, span := startSpan(ctx, "INSERT", table)
+	defer func() { endSpan(span, err) }()
+
+	result, err := s.conn(ctx).ExecContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	return affected(result, ErrConflict)
+}
+
+// delete runs a DELETE of one of a user's records
+func (s *Postgres) delete(ctx context.Context, table, userID, id string) (err error) {
+	ctx, span := startSpan(ctx, "DELETE", table)
+	defer func() { endSpan(span, err) }()
+
+	result, err := s.conn(ctx).ExecContext(ctx, "DELETE FROM "+table+" WHERE id = $1 AND user_id = $2", id, userID)
+	if err != nil {
+		return err
+	}
+	return affected(result, ErrNotFound)
+}
+
+// affected returns errNone if a statement changed no rows
+func affected(result sql.Result, errNone error) error {
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return errNone
+	}
+	return nil
+}
+
+// notFound turns sql.ErrNoRows into ErrNotFound
+func notFound(err error) error {
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrNotFound
+	}
+	return err
+}
+
+// page appends the time range, order and limit of opts to a query whose
+// WHERE clause has used len(args) placeholders
+func page(query, column string, opts ListOptions, args []any) (string, []any) {
+	if !opts.Since.IsZero() {
+		args = append(args, opts.Since.UTC())
+		query += fmt.Sprintf(" AND %s >= $%d", column, len(args))
+	}
+	if !opts.Until.IsZero() {
+		args = append(args, opts.Until.UTC())
+		query += fmt.Sprintf(" AND %s < $%d", column, len(args))
+	}
+	args = append(args, opts.limit())
+	query += fmt.Sprintf(" ORDER BY %s DESC, id DESC LIMIT $%d", column, len(args))
+	return query, args
+}
+
+// scanner is a *sql.Row or *sql.Rows
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+// postgresUsers is the UserRepository of a Postgres
+type postgresUsers struct{ s *Postgres }
+
+const userColumns = "id, email, name, password_hash, roles, created_at"
+
+// Create implements UserRepository
+func (r postgresUsers) Create(ctx context.Context, user *User) error {
+	user.ID = newID(user.ID)
+	user.CreatedAt = timestamp(user.CreatedAt)
+	return r.s.insert(ctx, "users",
+		"INSERT INTO users ("+userColumns+") VALUES ($1, $2, $3, $4, $5, $6) ON CONFLICT DO NOTHING",
+		user.ID, user.Email, user.Name, user.PasswordHash, strings.Join(user.Roles, ","), user.CreatedAt)
+}
+
+// ByID implements UserRepository
+func (r postgresUsers) ByID(ctx context.Context, id string) (*User, error) {
+	return r.get(ctx, "id", id)
+}
+
+// ByEmail implements UserRepository
+func (r postgresUsers) ByEmail(ctx context.Context, email string) (*User, error) {
+	return r.get(ctx, "email", email)
+}
+
+// get returns the user whose column has value
+func (r postgresUsers) get(ctx context.Context, column, value string) (_ *User, err error) {
+	ctx, span := startSpan(ctx, "SELECT", "users")
+	defer func() { endSpan(span, err) }()
+
+	var user User
+	var roles string
+	err = r.s.conn(ctx).QueryRowContext(ctx, "SELECT "+userColumns+" FROM users WHERE "+column+" = $1", value).
+		Scan(&user.ID, &user.Email, &user.Name, &user.PasswordHash, &roles, &user.CreatedAt)
+	if err != nil {
+		return nil, notFound(err)
+	}
+	if roles != "" {
+		user.Roles = strings.Split(roles, ",")
+	}
+	user.CreatedAt = user.CreatedAt.UTC()
+	return &user, nil
+}
+
+// postgresActivities is the ActivityRepository of a Postgres
+type postgresActivities struct{ s *Postgres }
+
+const activityColumns = "id, user_id, type, duration_seconds, calories, distance_km, started_at, created_at"
+
+// Create implements ActivityRepository
+func (r postgresActivities) Create(ctx context.Context, activity *Activity) error {
+	activity.ID = newID(activity.ID)
+	activity.Duration = activity.Duration.Truncate(time.Second)
+	activity.StartedAt = timestamp(activity.StartedAt)
+	activity.CreatedAt = timestamp(activity.CreatedAt)
+	return r.s.insert(ctx, "activities",
+		"INSERT INTO activities ("+activityColumns+") VALUES ($1, $2, $3, $4, $5, $6, $7, $8) ON CONFLICT DO NOTHING",
+		activity.ID, activity.UserID, activity.Type, int64(activity.Duration/time.Second),
+		activity.Calories, activity.DistanceKM, activity.StartedAt, activity.CreatedAt)
+}
+
+// ByID implements ActivityRepository
+func (r postgresActivities) ByID(ctx context.Context, userID, id string) (_ *Activity, err error) {
+	ctx, span := startSpan(ctx, "SELECT", "activities")
+	defer func() { endSpan(span, err) }()
+
+	row := r.s.conn(ctx).QueryRowContext(ctx, "SELECT "+activityColumns+" FROM activities WHERE id = $1 AND user_id = $2", id, userID)
+	activity, err := scanActivity(row)
+	if err != nil {
+		return nil, notFound(err)
+	}
+	return &activity, nil
+}
+
+// List implements ActivityRepository
+func (r postgresActivities) List(ctx context.Context, userID string, opts ListOptions) (_ []Activity, err error) {
+	ctx, span := startSpan(ctx, "SELECT", "activities")
+	defer func() { endSpan(span, err) }()
+
+	query, args := page("SELECT "+activityColumns+" FROM activities WHERE user_id = $1", "started_at", opts, []any{userID})
+	rows, err := r.s.conn(ctx).QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var activities []Activity
+	for rows.Next() {
+		activity, err := scanActivity(rows)
+		if err != nil {
+			return nil, err
+		}
+		activities = append(activities, activity)
+	}
+	return activities, rows.Err()
+}
+
+// Delete implements ActivityRepository
+func (r postgresActivities) Delete(ctx context.Context, userID, id string) error {
+	return r.s.delete(ctx, "activities", userID, id)
+}
+
+// scanActivity reads an activity selected with activityColumns
+func scanActivity(row scanner) (Activity, error) {
+	var a Activity
+	var seconds int64
+	if err := row.Scan(&a.ID, &a.UserID, &a.Type, &seconds, &a.Calories, &a.DistanceKM, &a.StartedAt, &a.CreatedAt); err != nil {
+		return Activity{}, err
+	}
+	a.Duration = time.Duration(seconds) * time.Second
+	a.StartedAt, a.CreatedAt = a.StartedAt.UTC(), a.CreatedAt.UTC()
+	return a, nil
+}
+
+// postgresMeals is the MealRepository of a Postgres
+type postgresMeals struct{ s *Postgres }
+
+const mealColumns = "id, user_id, name, calories, eaten_at, created_at"
+
+// Create implements MealRepository
+func (r postgresMeals) Create(ctx context.Context, meal *Meal) error {
+	meal.ID = newID(meal.ID)
+	meal.EatenAt = timestamp(meal.EatenAt)
+	meal.CreatedAt = timestamp(meal.CreatedAt)
+	return r.s.insert(ctx, "meals",
+		"INSERT INTO meals ("+mealColumns+") VALUES ($1, $2, $3, $4, $5, $6) ON CONFLICT DO NOTHING",
+		meal.ID, meal.UserID, meal.Name, meal.Calories, meal.EatenAt, meal.CreatedAt)
+}
+
+// ByID implements MealRepository
+func (r postgresMeals) ByID(ctx context.Context, userID, id string) (_ *Meal, err error) {
+	ctx, span := startSpan(ctx, "SELECT", "meals")
+	defer func() { endSpan(span, err) }()
+
+	row := r.s.conn(ctx).QueryRowContext(ctx, "SELECT "+mealColumns+" FROM meals WHERE id = $1 AND user_id = $2", id, userID)
+	meal, err := scanMeal(row)
+	if err != nil {
+		return nil, notFound(err)
+	}
+	return &meal, nil
+}
+
+// List implements MealRepository
+func (r postgresMeals) List(ctx context.Context, userID string, opts ListOptions) (_ []Meal, err error) {
+	ctx, span := startSpan(ctx, "SELECT", "meals")
+	defer func() { endSpan(span, err) }()
+
+	query, args := page("SELECT "+mealColumns+" FROM meals WHERE user_id = $1", "eaten_at", opts, []any{userID})
+	rows, err := r.s.conn(ctx).QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var meals []Meal
+	for rows.Next() {
+		meal, err := scanMeal(rows)
+		if err != nil {
+			return nil, err
+		}
+		meals = append(meals, meal)
+	}
+	return meals, rows.Err()
+}
+
+// Delete implements MealRepository
+func (r postgresMeals) Delete(ctx context.Context, userID, id string) error {
+	return r.s.delete(ctx, "meals", userID, id)
+}
+
+// scanMeal reads a meal selected with mealColumns
+func scanMeal(row scanner) (Meal, error) {
+	var m Meal
+	if err := row.Scan(&m.ID, &m.UserID, &m.Name, &m.Calories, &m.EatenAt, &m.CreatedAt); err != nil {
+		return Meal{}, err
+	}
+	m.EatenAt, m.CreatedAt = m.EatenAt.UTC(), m.CreatedAt.UTC()
+	return m, nil
+}
+
+// postgresMessages is the MessageRepository of a Postgres
+type postgresMessages struct{ s *Postgres }
+
+const messageColumns = "id, sender_id, recipient_id, body, created_at, read_at"
+
+// Create implements MessageRepository
+func (r postgresMessages) Create(ctx context.Context, message *Message) error {
+	message.ID = newID(message.ID)
+	message.CreatedAt = timestamp(message.CreatedAt)
+	var readAt sql.NullTime
+	if message.ReadAt != nil {
+		readAt = sql.NullTime{Time: timestamp(*message.ReadAt), Valid: true}
+		message.ReadAt = &readAt.Time
+	}
+	return r.s.insert(ctx, "messages",
+		"INSERT INTO messages ("+messageColumns+") VALUES ($1, $2, $3, $4, $5, $6) ON CONFLICT DO NOTHING",
+		message.ID, message.SenderID, message.RecipientID, message.Body, message.CreatedAt, readAt)
+}
+
+// List implements MessageRepository
+func (r postgresMessages) List(ctx context.Context, userID string, opts ListOptions) (_ []Message, err error) {
+	ctx, span := startSpan(ctx, "SELECT", "messages")
+	defer func() { endSpan(span, err) }()
+
+	query, args := page("SELECT "+messageColumns+" FROM messages WHERE (sender_id = $1 OR recipient_id = $1)", "created_at", opts, []any{userID})
+	rows, err := r.s.conn(ctx).QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var messages []Message
+	for rows.Next() {
+		var m Message
+		var readAt sql.NullTime
+		if err := rows.Scan(&m.ID, &m.SenderID, &m.RecipientID, &m.Body, &m.CreatedAt, &readAt); err != nil {
+			return nil, err
+		}
+		m.CreatedAt = m.CreatedAt.UTC()
+		if readAt.Valid {
+			t := readAt.Time.UTC()
+			m.ReadAt = &t
+		}
+		messages = append(messages, m)
+	}
+	return messages, rows.Err()
+}
+
+// MarkRead implements MessageRepository
+func (r postgresMessages) MarkRead(ctx context.Context, recipientID, id string, at time.Time) (err error) {
+	ctx, span := startSpan(ctx, "UPDATE", "messages")
+	defer func() { endSpan(span, err) }()
+
+	result, err := r.s.conn(ctx).ExecContext(ctx,
+		"UPDATE messages SET read_at = COALESCE(read_at, $1) WHERE id = $2 AND recipient_id = $3",
+		timestamp(at), id, recipientID)
+	if err != nil {
+		return err
+	}
+	return affected(result, ErrNotFound)
+}
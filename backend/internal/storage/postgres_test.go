@@ -0,0 +1,71 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/migrate"
+	"github.com/timur-harin/sum25-go-flutter-course/backend/migrations"
+	_ "modernc.org/sqlite"
+)
+
+// TestPostgres runs the queries against SQLite, which understands the SQL
+// they use, and against Postgres itself when TEST_DATABASE_URL is set
+func TestPostgres(t *testing.T) {
+	t.Run("sqlite", func(t *testing.T) {
+		testStore(t, func(t *testing.T) Store {
+			db, err := sql.Open("sqlite", ":memory:")
+			if err != nil {
+				t.Fatalf("open database: %v", err)
+			}
+			// Each connection to :memory: is a separate database
+			db.SetMaxOpenConns(1)
+			t.Cleanup(func() { db.Close() })
+			migrateTestDB(t, db, true)
+			return NewPostgres(db)
+		})
+	})
+
+	t.Run("postgres", func(t *testing.T) {
+		url := os.Getenv("TEST_DATABASE_URL")
+		if url == "" {
+			t.Skip("TEST_DATABASE_URL is not set")
+		}
+		db, err := Open(url, PoolConfig{MaxOpenConns: 4})
+		if err != nil {
+			t.Fatalf("Open: %v", err)
+		}
+		t.Cleanup(func() { db.Close() })
+		migrateTestDB(t, db, false)
+
+		testStore(t, func(t *testing.T) Store {
+			if _, err := db.Exec("TRUNCATE users, activities, meals, messages CASCADE"); err != nil {
+				t.Fatalf("truncate: %v", err)
+			}
+			return NewPostgres(db)
+		})
+	})
+}
+
+// migrateTestDB applies the migrations the server ships with. SQLite only
+// reads columns declared TIMESTAMP back as time.Time, so for it TIMESTAMPTZ
+// is declared as TIMESTAMP.
+func migrateTestDB(t *testing.T, db *sql.DB, sqlite bool) {
+	t.Helper()
+	all, err := migrate.Load(migrations.FS)
+	if err != nil {
+		t.Fatalf("load migrations: %v", err)
+	}
+	if sqlite {
+		for _, m := range all {
+			m.Up = strings.ReplaceAll(m.Up, "TIMESTAMPTZ", "TIMESTAMP")
+		}
+	}
+	if err := migrate.New(db, all).Up(context.Background(), 0); err != nil && !errors.Is(err, migrate.ErrNoChange) {
+		t.Fatalf("apply migrations: %v", err)
+	}
+}
@@ -0,0 +1,161 @@
+// Package storage defines the repositories the API keeps its data in, with
+// a Postgres implementation and an in-memory one for tests and development
+// that pass the same tests.
+package storage
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var (
+	// ErrNotFound is returned when no record matches
+	ErrNotFound = errors.New("not found")
+	// ErrConflict is returned when a record would break a uniqueness rule,
+	// such as a second user with the same email
+	ErrConflict = errors.New("already exists")
+)
+
+// List limits
+const (
+	DefaultLimit = 50
+	MaxLimit     = 500
+)
+
+// User is a registered account
+type User struct {
+	ID           string
+	Email        string
+	Name         string
+	PasswordHash string
+	Roles        []string
+	CreatedAt    time.Time
+}
+
+// Activity is a logged workout
+type Activity struct {
+	ID         string
+	UserID     string
+	Type       string
+	Duration   time.Duration
+	Calories   int
+	DistanceKM float64
+	StartedAt  time.Time
+	CreatedAt  time.Time
+}
+
+// Meal is a logged meal
+type Meal struct {
+	ID        string
+	UserID    string
+	Name      string
+	Calories  int
+	EatenAt   time.Time
+	CreatedAt time.Time
+}
+
+// Message is a direct message between two users
+type Message struct {
+	ID          string
+	SenderID    string
+	RecipientID string
+	Body        string
+	CreatedAt   time.Time
+	// ReadAt is nil until the recipient reads the message
+	ReadAt *time.Time
+}
+
+// ListOptions select a page of records by time, newest first. Since is
+// inclusive and Until exclusive; zero times leave that end open.
+type ListOptions struct {
+	Since time.Time
+	Until time.Time
+	// Limit is the page size: DefaultLimit when zero, at most MaxLimit
+	Limit int
+}
+
+// limit returns the page size to use
+func (o ListOptions) limit() int {
+	switch {
+	case o.Limit <= 0:
+		return DefaultLimit
+	case o.Limit > MaxLimit:
+		return MaxLimit
+	}
+	return o.Limit
+}
+
+// includes reports whether t is within the time range
+func (o ListOptions) includes(t time.Time) bool {
+	return (o.Since.IsZero() || !t.Before(o.Since)) && (o.Until.IsZero() || t.Before(o.Until))
+}
+
+// UserRepository stores users. Create fills in an empty ID and CreatedAt.
+type UserRepository interface {
+	// Create stores a new user, or returns ErrConflict if the email is taken
+	Create(ctx context.Context, user *User) error
+	ByID(ctx context.Context, id string) (*User, error)
+	ByEmail(ctx context.Context, email string) (*User, error)
+}
+
+// ActivityRepository stores activities. Reads and deletes are scoped to
+// the owning user, so one user's IDs never reach another's records.
+type ActivityRepository interface {
+	Create(ctx context.Context, activity *Activity) error
+	ByID(ctx context.Context, userID, id string) (*Activity, error)
+	// List returns the user's activities by StartedAt, newest first
+	List(ctx context.Context, userID string, opts ListOptions) ([]Activity, error)
+	Delete(ctx context.Context, userID, id string) error
+}
+
+// MealRepository stores meals, scoped to the owning user like activities
+type MealRepository interface {
+	Create(ctx context.Context, meal *Meal) error
+	ByID(ctx context.Context, userID, id string) (*Meal, error)
+	// List returns the user's meals by EatenAt, newest first
+	List(ctx context.Context, userID string, opts ListOptions) ([]Meal, error)
+	Delete(ctx context.Context, userID, id string) error
+}
+
+// MessageRepository stores direct messages
+type MessageRepository interface {
+	Create(ctx context.Context, message *Message) error
+	// List returns the messages the user sent or received, newest first
+	List(ctx context.Context, userID string, opts ListOptions) ([]Message, error)
+	// MarkRead records when the recipient read a message. Messages read
+	// before keep their first ReadAt.
+	MarkRead(ctx context.Context, recipientID, id string, at time.Time) error
+}
+
+// Store gives access to every repository
+type Store interface {
+	Users() UserRepository
+	Activities() ActivityRepository
+	Meals() MealRepository
+	Messages() MessageRepository
+	// WithinTx runs fn as a unit of work: repository calls made with the
+	// ctx passed to fn are committed together when fn returns nil and
+	// rolled back when it returns an error or panics. Calls made within
+	// an existing unit of work join it.
+	WithinTx(ctx context.Context, fn func(ctx context.Context) error) error
+}
+
+// newID returns id, or a new random ID if it is empty
+func newID(id string) string {
+	if id == "" {
+		return uuid.NewString()
+	}
+	return id
+}
+
+// timestamp returns t as stored: in UTC at the microsecond precision
+// Postgres keeps, or the current time if t is zero
+func timestamp(t time.Time) time.Time {
+	if t.IsZero() {
+		t = time.Now()
+	}
+	return t.UTC().Truncate(time.Microsecond)
+}
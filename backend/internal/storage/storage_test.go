@@ -0,0 +1,358 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+)
+
+// base is a whole second, so every store keeps it exactly
+var base = time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+
+// testStore runs the behaviour every Store must share. newStore returns an
+// empty store for each subtest.
+func testStore(t *testing.T, newStore func(t *testing.T) Store) {
+	t.Run("users", func(t *testing.T) { testUsers(t, newStore(t)) })
+	t.Run("activities", func(t *testing.T) { testActivities(t, newStore(t)) })
+	t.Run("meals", func(t *testing.T) { testMeals(t, newStore(t)) })
+	t.Run("messages", func(t *testing.T) { testMessages(t, newStore(t)) })
+	t.Run("list options", func(t *testing.T) { testListOptions(t, newStore(t)) })
+	t.Run("unit of work", func(t *testing.T) { testWithinTx(t, newStore(t)) })
+}
+
+// createUser stores a user with the given email for records to belong to
+func createUser(t *testing.T, s Store, email string) *User {
+	t.Helper()
+	user := &User{Email: email, PasswordHash: "hash"}
+	if err := s.Users().Create(context.Background(), user); err != nil {
+		t.Fatalf("Create user %s: %v", email, err)
+	}
+	return user
+}
+
+func testUsers(t *testing.T, s Store) {
+	ctx := context.Background()
+	users := s.Users()
+
+	// A time outside UTC with nanoseconds comes back in UTC to the microsecond
+	created := time.Date(2026, 10, 1, 15, 0, 0, 123456789, time.FixedZone("MSK", 3*60*60))
+	alice := &User{Email: "alice@example.com", Name: "Alice", PasswordHash: "hash", Roles: []string{"user", "admin"}, CreatedAt: created}
+	if err := users.Create(ctx, alice); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if alice.ID == "" {
+		t.Error("Expected Create to fill in the ID")
+	}
+	if want := base.Add(123456 * time.Microsecond); !alice.CreatedAt.Equal(want) || alice.CreatedAt.Location() != time.UTC {
+		t.Errorf("Expected CreatedAt %v, got %v", want, alice.CreatedAt)
+	}
+
+	for name, get := range map[string]func() (*User, error){
+		"ByID":    func() (*User, error) { return users.ByID(ctx, alice.ID) },
+		"ByEmail": func() (*User, error) { return users.ByEmail(ctx, "alice@example.com") },
+	} {
+		got, err := get()
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if got.ID != alice.ID || got.Email != alice.Email || got.Name != "Alice" || got.PasswordHash != "hash" ||
+			!slices.Equal(got.Roles, alice.Roles) || !got.CreatedAt.Equal(alice.CreatedAt) {
+			t.Errorf("%s: expected %+v, got %+v", name, alice, got)
+		}
+	}
+
+	bob := createUser(t, s, "bob@example.com")
+	if got, err := users.ByID(ctx, bob.ID); err != nil || len(got.Roles) != 0 || got.CreatedAt.IsZero() {
+		t.Errorf("Expected no roles and a creation time, got %+v, %v", got, err)
+	}
+
+	if err := users.Create(ctx, &User{Email: "alice@example.com", PasswordHash: "other"}); !errors.Is(err, ErrConflict) {
+		t.Errorf("Expected ErrConflict for a taken email, got %v", err)
+	}
+	if err := users.Create(ctx, &User{ID: alice.ID, Email: "carol@example.com"}); !errors.Is(err, ErrConflict) {
+		t.Errorf("Expected ErrConflict for a taken ID, got %v", err)
+	}
+	if _, err := users.ByID(ctx, "missing"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound by ID, got %v", err)
+	}
+	if _, err := users.ByEmail(ctx, "carol@example.com"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound by email, got %v", err)
+	}
+}
+
+func testActivities(t *testing.T, s Store) {
+	ctx := context.Background()
+	activities := s.Activities()
+	alice := createUser(t, s, "alice@example.com")
+	bob := createUser(t, s, "bob@example.com")
+
+	run := &Activity{UserID: alice.ID, Type: "run", Duration: 30*time.Minute + 500*time.Millisecond, Calories: 300, DistanceKM: 5.5, StartedAt: base}
+	if err := activities.Create(ctx, run); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if run.ID == "" || run.CreatedAt.IsZero() {
+		t.Errorf("Expected Create to fill in the ID and CreatedAt, got %+v", run)
+	}
+
+	got, err := activities.ByID(ctx, alice.ID, run.ID)
+	if err != nil {
+		t.Fatalf("ByID: %v", err)
+	}
+	if *got != *run || got.Duration != 30*time.Minute {
+		t.Errorf("Expected %+v with the duration in whole seconds, got %+v", run, got)
+	}
+
+	if _, err := activities.ByID(ctx, bob.ID, run.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected another user's activity to be not found, got %v", err)
+	}
+	if err := activities.Create(ctx, &Activity{ID: run.ID, UserID: alice.ID, Type: "swim"}); !errors.Is(err, ErrConflict) {
+		t.Errorf("Expected ErrConflict for a taken ID, got %v", err)
+	}
+
+	if err := activities.Delete(ctx, bob.ID, run.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected deleting another user's activity to be not found, got %v", err)
+	}
+	if err := activities.Delete(ctx, alice.ID, run.ID); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if _, err := activities.ByID(ctx, alice.ID, run.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected a deleted activity to be not found, got %v", err)
+	}
+	if err := activities.Delete(ctx, alice.ID, run.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected deleting twice to be not found, got %v", err)
+	}
+}
+
+func testMeals(t *testing.T, s Store) {
+	ctx := context.Background()
+	meals := s.Meals()
+	alice := createUser(t, s, "alice@example.com")
+	bob := createUser(t, s, "bob@example.com")
+
+	lunch := &Meal{UserID: alice.ID, Name: "Lunch", Calories: 650, EatenAt: base}
+	if err := meals.Create(ctx, lunch); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if lunch.ID == "" || lunch.CreatedAt.IsZero() {
+		t.Errorf("Expected Create to fill in the ID and CreatedAt, got %+v", lunch)
+	}
+
+	got, err := meals.ByID(ctx, alice.ID, lunch.ID)
+	if err != nil {
+		t.Fatalf("ByID: %v", err)
+	}
+	if *got != *lunch {
+		t.Errorf("Expected %+v, got %+v", lunch, got)
+	}
+
+	if _, err := meals.ByID(ctx, bob.ID, lunch.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected another user's meal to be not found, got %v", err)
+	}
+	if err := meals.Create(ctx, &Meal{ID: lunch.ID, UserID: alice.ID, Name: "Dinner"}); !errors.Is(err, ErrConflict) {
+		t.Errorf("Expected ErrConflict for a taken ID, got %v", err)
+	}
+
+	if err := meals.Delete(ctx, bob.ID, lunch.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected deleting another user's meal to be not found, got %v", err)
+	}
+	if err := meals.Delete(ctx, alice.ID, lunch.ID); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if _, err := meals.ByID(ctx, alice.ID, lunch.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected a deleted meal to be not found, got %v", err)
+	}
+}
+
+func testMessages(t *testing.T, s Store) {
+	ctx := context.Background()
+	messages := s.Messages()
+	alice := createUser(t, s, "alice@example.com")
+	bob := createUser(t, s, "bob@example.com")
+	carol := createUser(t, s, "carol@example.com")
+
+	hello := &Message{SenderID: alice.ID, RecipientID: bob.ID, Body: "hello", CreatedAt: base}
+	reply := &Message{SenderID: bob.ID, RecipientID: alice.ID, Body: "hi", CreatedAt: base.Add(time.Minute)}
+	other := &Message{SenderID: bob.ID, RecipientID: carol.ID, Body: "hey", CreatedAt: base.Add(2 * time.Minute)}
+	for _, m := range []*Message{hello, reply, other} {
+		if err := messages.Create(ctx, m); err != nil {
+			t.Fatalf("Create: %v", err)
+		}
+	}
+	if hello.ID == "" || hello.ReadAt != nil {
+		t.Errorf("Expected an ID and an unread message, got %+v", hello)
+	}
+
+	got, err := messages.List(ctx, alice.ID, ListOptions{})
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if len(got) != 2 || got[0].ID != reply.ID || got[1].ID != hello.ID {
+		t.Fatalf("Expected the sent and received messages newest first, got %+v", got)
+	}
+	if got[1].Body != "hello" || got[1].SenderID != alice.ID || got[1].RecipientID != bob.ID || got[1].ReadAt != nil {
+		t.Errorf("Expected %+v, got %+v", hello, got[1])
+	}
+
+	if err := messages.MarkRead(ctx, alice.ID, hello.ID, base.Add(time.Hour)); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected the sender marking a message read to be not found, got %v", err)
+	}
+	if err := messages.MarkRead(ctx, bob.ID, "missing", base); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound for a missing message, got %v", err)
+	}
+	if err := messages.MarkRead(ctx, bob.ID, hello.ID, base.Add(time.Hour)); err != nil {
+		t.Fatalf("MarkRead: %v", err)
+	}
+	if err := messages.MarkRead(ctx, bob.ID, hello.ID, base.Add(2*time.Hour)); err != nil {
+		t.Fatalf("MarkRead again: %v", err)
+	}
+
+	got, err = messages.List(ctx, bob.ID, ListOptions{Until: base.Add(time.Minute)})
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if len(got) != 1 || got[0].ReadAt == nil || !got[0].ReadAt.Equal(base.Add(time.Hour)) {
+		t.Errorf("Expected the first read time to be kept, got %+v", got)
+	}
+}
+
+func testListOptions(t *testing.T, s Store) {
+	ctx := context.Background()
+	alice := createUser(t, s, "alice@example.com")
+	bob := createUser(t, s, "bob@example.com")
+
+	// Five meals an hour apart, and two at the same time ordered by ID
+	for i := range 5 {
+		if err := s.Meals().Create(ctx, &Meal{ID: string(rune('a' + i)), UserID: alice.ID, EatenAt: base.Add(time.Duration(i) * time.Hour)}); err != nil {
+			t.Fatalf("Create: %v", err)
+		}
+	}
+	if err := s.Meals().Create(ctx, &Meal{ID: "z", UserID: alice.ID, EatenAt: base.Add(4 * time.Hour)}); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if err := s.Meals().Create(ctx, &Meal{ID: "bob", UserID: bob.ID, EatenAt: base}); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	for i := range 3 {
+		if err := s.Activities().Create(ctx, &Activity{UserID: alice.ID, Type: "walk", StartedAt: base.Add(time.Duration(i) * time.Hour)}); err != nil {
+			t.Fatalf("Create: %v", err)
+		}
+	}
+
+	tests := []struct {
+		name string
+		opts ListOptions
+		want []string
+	}{
+		{"all", ListOptions{}, []string{"z", "e", "d", "c", "b", "a"}},
+		{"limit", ListOptions{Limit: 2}, []string{"z", "e"}},
+		{"since is inclusive", ListOptions{Since: base.Add(3 * time.Hour)}, []string{"z", "e", "d"}},
+		{"until is exclusive", ListOptions{Until: base.Add(2 * time.Hour)}, []string{"b", "a"}},
+		{"range", ListOptions{Since: base.Add(time.Hour), Until: base.Add(3 * time.Hour), Limit: 10}, []string{"c", "b"}},
+		{"range outside UTC", ListOptions{Since: base.Add(time.Hour).In(time.FixedZone("EST", -5*60*60))}, []string{"z", "e", "d", "c", "b"}},
+		{"empty range", ListOptions{Since: base.Add(10 * time.Hour)}, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			meals, err := s.Meals().List(ctx, alice.ID, tt.opts)
+			if err != nil {
+				t.Fatalf("List: %v", err)
+			}
+			var got []string
+			for _, m := range meals {
+				got = append(got, m.ID)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("Expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+
+	activities, err := s.Activities().List(ctx, alice.ID, ListOptions{Limit: 2})
+	if err != nil {
+		t.Fatalf("List activities: %v", err)
+	}
+	if len(activities) != 2 || !activities[0].StartedAt.Equal(base.Add(2*time.Hour)) || !activities[1].StartedAt.Equal(base.Add(time.Hour)) {
+		t.Errorf("Expected the two latest activities, got %+v", activities)
+	}
+}
+
+func testWithinTx(t *testing.T, s Store) {
+	ctx := context.Background()
+	errAbort := errors.New("abort")
+
+	// A unit of work that fails leaves nothing behind, nested ones included
+	err := s.WithinTx(ctx, func(ctx context.Context) error {
+		alice := createUserIn(ctx, t, s, "alice@example.com")
+		if err := s.Meals().Create(ctx, &Meal{UserID: alice.ID, Name: "Lunch", EatenAt: base}); err != nil {
+			return err
+		}
+		return s.WithinTx(ctx, func(ctx context.Context) error {
+			createUserIn(ctx, t, s, "bob@example.com")
+			return errAbort
+		})
+	})
+	if !errors.Is(err, errAbort) {
+		t.Fatalf("Expected the unit of work's error, got %v", err)
+	}
+	for _, email := range []string{"alice@example.com", "bob@example.com"} {
+		if _, err := s.Users().ByEmail(ctx, email); !errors.Is(err, ErrNotFound) {
+			t.Errorf("Expected %s to be rolled back, got %v", email, err)
+		}
+	}
+
+	// A panic rolls back too and carries on up
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Error("Expected the panic to be re-raised")
+			}
+		}()
+		_ = s.WithinTx(ctx, func(ctx context.Context) error {
+			createUserIn(ctx, t, s, "carol@example.com")
+			panic("boom")
+		})
+	}()
+	if _, err := s.Users().ByEmail(ctx, "carol@example.com"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected a panicking unit of work to be rolled back, got %v", err)
+	}
+
+	// A unit of work that succeeds commits everything
+	var lunch *Meal
+	err = s.WithinTx(ctx, func(ctx context.Context) error {
+		alice := createUserIn(ctx, t, s, "alice@example.com")
+		lunch = &Meal{UserID: alice.ID, Name: "Lunch", EatenAt: base}
+		if err := s.Meals().Create(ctx, lunch); err != nil {
+			return err
+		}
+		// Reads within the unit of work see its writes
+		_, err := s.Meals().ByID(ctx, alice.ID, lunch.ID)
+		return err
+	})
+	if err != nil {
+		t.Fatalf("WithinTx: %v", err)
+	}
+	if _, err := s.Meals().ByID(ctx, lunch.UserID, lunch.ID); err != nil {
+		t.Errorf("Expected the committed meal, got %v", err)
+	}
+
+	// Repository errors surface from the unit of work unchanged
+	err = s.WithinTx(ctx, func(ctx context.Context) error {
+		return s.Users().Create(ctx, &User{Email: "alice@example.com", PasswordHash: "hash"})
+	})
+	if !errors.Is(err, ErrConflict) {
+		t.Errorf("Expected ErrConflict from the unit of work, got %v", err)
+	}
+}
+
+// createUserIn stores a user within the unit of work of ctx
+func createUserIn(ctx context.Context, t *testing.T, s Store, email string) *User {
+	t.Helper()
+	user := &User{Email: email, PasswordHash: "hash"}
+	if err := s.Users().Create(ctx, user); err != nil {
+		t.Fatalf("Create user %s: %v", email, err)
+	}
+	return user
+}
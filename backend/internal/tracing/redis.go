@@ -0,0 +1,55 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+
+	"github.com/redis/go-redis/v9"
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
+)
+
+// RedisHook records a client span for each Redis command or pipeline. Add
+// it with redis.Client.AddHook.
+type RedisHook struct{}
+
+// DialHook implements redis.Hook
+func (RedisHook) DialHook(next redis.DialHook) redis.DialHook {
+	return next
+}
+
+// ProcessHook implements redis.Hook
+func (RedisHook) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+	return func(ctx context.Context, cmd redis.Cmder) error {
+		ctx, span := StartClient(ctx, "redis "+cmd.Name(),
+			semconv.DBSystemNameRedis,
+			semconv.DBOperationName(cmd.Name()),
+		)
+		err := next(ctx, cmd)
+		End(span, redisError(err))
+		return err
+	}
+}
+
+// ProcessPipelineHook implements redis.Hook
+func (RedisHook) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return func(ctx context.Context, cmds []redis.Cmder) error {
+		ctx, span := StartClient(ctx, "redis pipeline",
+			semconv.DBSystemNameRedis,
+			semconv.DBOperationName("pipeline"),
+			attribute.Int("db.operation.batch.size", len(cmds)),
+		)
+		err := next(ctx, cmds)
+		End(span, redisError(err))
+		return err
+	}
+}
+
+// redisError drops redis.Nil, which reports a missing key rather than a
+// failure
+func redisError(err error) error {
+	if errors.Is(err, redis.Nil) {
+		return nil
+	}
+	return err
+}
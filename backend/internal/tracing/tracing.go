@@ -0,0 +1,105 @@
+// Package tracing sets up OpenTelemetry tracing: the tracer provider and its
+// exporter, W3C trace context propagation, and client spans for calls to
+// storage and other services
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Name is the instrumentation scope of the spans created by the backend
+const Name = "github.com/timur-harin/sum25-go-flutter-course/backend"
+
+// Propagator reads and writes the W3C traceparent, tracestate and baggage
+// headers
+var Propagator = propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{})
+
+// Config selects where spans are sent
+type Config struct {
+	// Exporter is none, stdout or otlp
+	Exporter string
+	// Endpoint is the OTLP/HTTP collector URL, e.g.
+	// http://otel-collector:4318. When empty the standard
+	// OTEL_EXPORTER_OTLP_* variables apply.
+	Endpoint string
+	// SampleRatio is the fraction of new traces recorded; traces started
+	// by a caller follow the caller's decision
+	SampleRatio    float64
+	ServiceName    string
+	ServiceVersion string
+}
+
+// NewExporter returns the exporter selected by cfg, or nil for none. stdout
+// receives the spans of the stdout exporter.
+func NewExporter(ctx context.Context, cfg Config, stdout io.Writer) (sdktrace.SpanExporter, error) {
+	switch cfg.Exporter {
+	case "", "none":
+		return nil, nil
+	case "stdout":
+		return stdouttrace.New(stdouttrace.WithWriter(stdout))
+	case "otlp":
+		var opts []otlptracehttp.Option
+		if cfg.Endpoint != "" {
+			opts = append(opts, otlptracehttp.WithEndpointURL(cfg.Endpoint))
+		}
+		return otlptracehttp.New(ctx, opts...)
+	default:
+		return nil, fmt.Errorf("unknown trace exporter %q", cfg.Exporter)
+	}
+}
+
+// NewProvider returns a tracer provider batching spans to exporter and
+// installs it, with Propagator, as the global default for libraries that
+// use otel directly. Shut it down to flush the last spans.
+//
+// Without an exporter nothing is batched and new traces aren't sampled,
+// but spans still carry trace IDs for the logs and trace context is still
+// passed on.
+func NewProvider(cfg Config, exporter sdktrace.SpanExporter) *sdktrace.TracerProvider {
+	sampler := sdktrace.TraceIDRatioBased(cfg.SampleRatio)
+	opts := []sdktrace.TracerProviderOption{
+		sdktrace.WithResource(resource.NewSchemaless(
+			semconv.ServiceName(cfg.ServiceName),
+			semconv.ServiceVersion(cfg.ServiceVersion),
+		)),
+	}
+	if exporter != nil {
+		opts = append(opts, sdktrace.WithBatcher(exporter))
+	} else {
+		sampler = sdktrace.NeverSample()
+	}
+	provider := sdktrace.NewTracerProvider(append(opts, sdktrace.WithSampler(sdktrace.ParentBased(sampler)))...)
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(Propagator)
+	return provider
+}
+
+// StartClient starts a client span for a call made while handling ctx,
+// such as a database query. The span comes from the provider of the span
+// in ctx, so calls outside a traced request are not recorded.
+func StartClient(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	tracer := trace.SpanFromContext(ctx).TracerProvider().Tracer(Name)
+	return tracer.Start(ctx, name, trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(attrs...))
+}
+
+// End records err, if any, on span and ends it
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
@@ -0,0 +1,169 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// startRequest returns a context holding a recorded span, standing in for
+// the server span of a request
+func startRequest(t *testing.T) (context.Context, *tracetest.SpanRecorder) {
+	t.Helper()
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	ctx, span := provider.Tracer("test").Start(context.Background(), "request")
+	t.Cleanup(func() { span.End() })
+	return ctx, recorder
+}
+
+func TestNewExporter(t *testing.T) {
+	tests := []struct {
+		exporter string
+		wantErr  bool
+	}{
+		{"", false},
+		{"none", false},
+		{"stdout", false},
+		{"otlp", false},
+		{"zipkin", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.exporter, func(t *testing.T) {
+			exporter, err := NewExporter(context.Background(), Config{Exporter: tt.exporter, Endpoint: "http://localhost:4318"}, &bytes.Buffer{})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Expected error %v, got %v", tt.wantErr, err)
+			}
+			if exporter != nil {
+				exporter.Shutdown(context.Background())
+			}
+		})
+	}
+}
+
+func TestProviderWithoutExporter(t *testing.T) {
+	exporter, err := NewExporter(context.Background(), Config{Exporter: "none"}, &bytes.Buffer{})
+	if err != nil || exporter != nil {
+		t.Fatalf("Expected no exporter for none, got %v, %v", exporter, err)
+	}
+	provider := NewProvider(Config{SampleRatio: 1}, exporter)
+	defer provider.Shutdown(context.Background())
+
+	_, span := provider.Tracer("test").Start(context.Background(), "work")
+	defer span.End()
+	if span.IsRecording() {
+		t.Error("Expected spans not to be recorded without an exporter")
+	}
+	if !span.SpanContext().HasTraceID() {
+		t.Error("Expected spans to still carry a trace ID")
+	}
+}
+
+func TestStdoutExporter(t *testing.T) {
+	var buf bytes.Buffer
+	exporter, _ := NewExporter(context.Background(), Config{Exporter: "stdout"}, &buf)
+	provider := NewProvider(Config{SampleRatio: 1, ServiceName: "test-service"}, exporter)
+
+	_, span := provider.Tracer("test").Start(context.Background(), "work")
+	span.End()
+	provider.Shutdown(context.Background())
+
+	if !bytes.Contains(buf.Bytes(), []byte(`"Name":"work"`)) || !bytes.Contains(buf.Bytes(), []byte("test-service")) {
+		t.Errorf("Expected the span to be written to stdout, got %s", buf.String())
+	}
+}
+
+func TestStartClient(t *testing.T) {
+	_, span := StartClient(context.Background(), "untraced")
+	if span.IsRecording() {
+		t.Error("Expected calls outside a traced request not to be recorded")
+	}
+
+	ctx, recorder := startRequest(t)
+	_, span = StartClient(ctx, "query")
+	End(span, context.DeadlineExceeded)
+
+	spans := recorder.Ended()
+	if len(spans) != 1 {
+		t.Fatalf("Expected one span, got %d", len(spans))
+	}
+	if spans[0].SpanKind() != trace.SpanKindClient || spans[0].Parent().SpanID() != trace.SpanContextFromContext(ctx).SpanID() {
+		t.Error("Expected a client span under the request span")
+	}
+	if spans[0].Status().Code != codes.Error {
+		t.Errorf("Expected the error to be recorded, got status %v", spans[0].Status())
+	}
+}
+
+func TestRedisHook(t *testing.T) {
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	t.Cleanup(func() { client.Close() })
+	client.AddHook(RedisHook{})
+	// Open the connection outside the request so its handshake isn't traced
+	client.Ping(context.Background())
+
+	ctx, recorder := startRequest(t)
+	client.Set(ctx, "k", "v", 0)
+	client.Get(ctx, "missing")
+	client.Pipelined(ctx, func(p redis.Pipeliner) error {
+		p.Incr(ctx, "a")
+		p.Incr(ctx, "b")
+		return nil
+	})
+
+	spans := recorder.Ended()
+	names := make([]string, len(spans))
+	for i, s := range spans {
+		names[i] = s.Name()
+		if s.Status().Code == codes.Error {
+			t.Errorf("Expected %s not to fail", s.Name())
+		}
+	}
+	want := []string{"redis set", "redis get", "redis pipeline"}
+	if len(names) != len(want) || names[0] != want[0] || names[1] != want[1] || names[2] != want[2] {
+		t.Errorf("Expected spans %v, got %v", want, names)
+	}
+}
+
+func TestTransportPropagatesTrace(t *testing.T) {
+	var traceparent string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		traceparent = r.Header.Get("traceparent")
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer server.Close()
+
+	ctx, recorder := startRequest(t)
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, server.URL, nil)
+	resp, err := (&http.Client{Transport: &Transport{}}).Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	spans := recorder.Ended()
+	if len(spans) != 1 {
+		t.Fatalf("Expected one client span, got %d", len(spans))
+	}
+	want := "00-" + spans[0].SpanContext().TraceID().String() + "-" + spans[0].SpanContext().SpanID().String() + "-01"
+	if traceparent != want {
+		t.Errorf("Expected traceparent %q, got %q", want, traceparent)
+	}
+	if req.Header.Get("traceparent") != "" {
+		t.Error("Expected the caller's request to be left unchanged")
+	}
+	if spans[0].Status().Code != codes.Error {
+		t.Error("Expected a 502 response to mark the span as failed")
+	}
+}
@@ -0,0 +1,42 @@
+package tracing
+
+import (
+	"net/http"
+
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
+)
+
+// Transport records a client span for each outgoing request and passes the
+// trace on in the traceparent header, so calls to other services join the
+// trace of the request that made them
+type Transport struct {
+	// Base makes the requests; http.DefaultTransport when nil
+	Base http.RoundTripper
+}
+
+// RoundTrip implements http.RoundTripper
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx, span := StartClient(req.Context(), "HTTP "+req.Method,
+		semconv.HTTPRequestMethodKey.String(req.Method),
+		semconv.ServerAddress(req.URL.Hostname()),
+	)
+
+	req = req.Clone(ctx)
+	Propagator.Inject(ctx, propagation.HeaderCarrier(req.Header))
+
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	resp, err := base.RoundTrip(req)
+	if err == nil {
+		span.SetAttributes(semconv.HTTPResponseStatusCode(resp.StatusCode))
+		if resp.StatusCode >= http.StatusBadRequest {
+			span.SetStatus(codes.Error, resp.Status)
+		}
+	}
+	End(span, err)
+	return resp, err
+}
@@ -0,0 +1,2 @@
+-- 0001_create_users (created 2026-10-17T01:39:55Z)
+DROP TABLE IF EXISTS users;
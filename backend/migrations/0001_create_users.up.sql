@@ -0,0 +1,9 @@
+-- 0001_create_users (created 2026-10-17T01:39:55Z)
+CREATE TABLE users (
+    id            TEXT PRIMARY KEY,
+    email         TEXT NOT NULL UNIQUE,
+    name          TEXT NOT NULL DEFAULT '',
+    password_hash TEXT NOT NULL,
+    roles         TEXT NOT NULL DEFAULT '',
+    created_at    TIMESTAMPTZ NOT NULL
+);
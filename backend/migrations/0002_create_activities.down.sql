@@ -0,0 +1,2 @@
+-- 0002_create_activities (created 2026-10-17T01:39:55Z)
+DROP TABLE IF EXISTS activities;
@@ -0,0 +1,13 @@
+-- 0002_create_activities (created 2026-10-17T01:39:55Z)
+CREATE TABLE activities (
+    id               TEXT PRIMARY KEY,
+    user_id          TEXT NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    type             TEXT NOT NULL,
+    duration_seconds BIGINT NOT NULL,
+    calories         INTEGER NOT NULL DEFAULT 0,
+    distance_km      DOUBLE PRECISION NOT NULL DEFAULT 0,
+    started_at       TIMESTAMPTZ NOT NULL,
+    created_at       TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX activities_user_started ON activities (user_id, started_at DESC);
@@ -0,0 +1,2 @@
+-- 0003_create_meals (created 2026-10-17T01:39:56Z)
+DROP TABLE IF EXISTS meals;
@@ -0,0 +1,11 @@
+-- 0003_create_meals (created 2026-10-17T01:39:56Z)
+CREATE TABLE meals (
+    id         TEXT PRIMARY KEY,
+    user_id    TEXT NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    name       TEXT NOT NULL,
+    calories   INTEGER NOT NULL DEFAULT 0,
+    eaten_at   TIMESTAMPTZ NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX meals_user_eaten ON meals (user_id, eaten_at DESC);
@@ -0,0 +1,2 @@
+-- 0004_create_messages (created 2026-10-17T01:39:56Z)
+DROP TABLE IF EXISTS messages;
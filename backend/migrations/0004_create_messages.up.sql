@@ -0,0 +1,12 @@
+-- 0004_create_messages (created 2026-10-17T01:39:56Z)
+CREATE TABLE messages (
+    id           TEXT PRIMARY KEY,
+    sender_id    TEXT NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    recipient_id TEXT NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    body         TEXT NOT NULL,
+    created_at   TIMESTAMPTZ NOT NULL,
+    read_at      TIMESTAMPTZ
+);
+
+CREATE INDEX messages_sender_created ON messages (sender_id, created_at DESC);
+CREATE INDEX messages_recipient_created ON messages (recipient_id, created_at DESC);
@@ -0,0 +1,2 @@
+-- 0005_create_refresh_tokens (created 2026-10-17T01:53:27Z)
+DROP TABLE IF EXISTS refresh_tokens;
@@ -0,0 +1,10 @@
+package migrations
+
+import "embed"
+
+// FS holds the migration files compiled into the binary, so the server can
+// apply them without the directory being present at runtime. Load ignores
+// everything that isn't a NNNN_name.(up|down).sql file.
+//
+//go:embed *
+var FS embed.FS
@@ -0,0 +1,12 @@
+// Package migrations holds the versioned schema history applied by
+// cmd/migrate. SQL steps live in NNNN_name.up.sql / NNNN_name.down.sql files.
+// Steps that need Go logic, such as data backfills, live next to them in a
+// NNNN_name.go file and register themselves from init:
+//
+//	func init() {
+//		migrate.Register(5, "backfill_calories", upBackfillCalories, downBackfillCalories)
+//	}
+//
+// Go steps receive the transaction the version is recorded in and the
+// loaded *config.Config.
+package migrations